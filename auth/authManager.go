@@ -1,80 +1,246 @@
 package auth
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"twitch-caster/models"
 )
 
+const defaultOAuthURL = "https://id.twitch.tv"
+
+// Requests to the Twitch OAuth server give up after this long
+const defaultAuthTimeout = 10 * time.Second
+
+// Tokens are renewed this long before they expire so requests never race the expiry
+const renewBefore = 5 * time.Minute
+
+// ErrNoUserToken is returned when a user access token is needed but nobody has logged in
+var ErrNoUserToken = errors.New("No Twitch user token available, log in or set twitchRefreshToken in configuration.json")
+
 type authResponse struct {
-	AccessToken string        `json:"access_token"`
-	ExpiresIn   time.Duration `json:"expires_in"`
+	AccessToken  string        `json:"access_token"`
+	RefreshToken string        `json:"refresh_token"`
+	ExpiresIn    time.Duration `json:"expires_in"`
 }
 
-var storedAuthResponse authResponse
-var expiresTime time.Time
-
 // Manager handles authentication for Twitch endpoints
 type Manager struct {
 	settings models.Settings
+	client   *http.Client
+	oauthURL string
+	store    TokenStore
+
+	// Held while a token is fetched, so only one request for each kind of token is ever in flight
+	appMutex  sync.Mutex
+	userMutex sync.Mutex
+}
+
+// NewManager creates a new Manager object that keeps its tokens in store
+func NewManager(settings models.Settings, store TokenStore) *Manager {
+	return NewManagerWithURL(settings, store, defaultOAuthURL)
 }
 
-// NewManager creates a new Manager object
-func NewManager(settings models.Settings) *Manager {
+// NewManagerWithURL creates a Manager that talks to the given Twitch OAuth server
+func NewManagerWithURL(settings models.Settings, store TokenStore, oauthURL string) *Manager {
+	return NewManagerWithClient(settings, store, &http.Client{Timeout: defaultAuthTimeout}, oauthURL)
+}
+
+// NewManagerWithClient creates a Manager that sends requests to the given Twitch OAuth server with httpClient
+func NewManagerWithClient(settings models.Settings, store TokenStore, httpClient *http.Client, oauthURL string) *Manager {
 	manager := Manager{}
 	manager.settings = settings
+	manager.client = httpClient
+	manager.oauthURL = strings.TrimSuffix(oauthURL, "/")
+	manager.store = store
+
+	// A configured refresh token is only a starting point, Twitch may rotate it on every refresh.
+	// Whose token it is gets validated on the first refresh rather than taken from userId.
+	if _, err := store.Load(userTokenKey); err != nil && settings.TwitchRefreshToken != "" {
+		store.Save(userTokenKey, Token{RefreshToken: settings.TwitchRefreshToken})
+	}
 	return &manager
 }
 
-// GetToken fetches a new bearer token used to make Twitch API requests
-func (a *Manager) GetToken() (string, error) {
-	if isSavedTokenValid() {
-		return storedAuthResponse.AccessToken, nil
+// UserID returns the ID of the logged in user, or the configured Twitch user ID if nobody has logged in
+func (a *Manager) UserID() string {
+	if token, err := a.store.Load(userTokenKey); err == nil && token.UserID != "" {
+		return token.UserID
 	}
+	return a.settings.UserID
+}
 
-	authURL := "https://id.twitch.tv/oauth2/token?client_id=" + a.settings.TwitchClientID + "&client_secret=" + a.settings.TwitchSecret + "&grant_type=client_credentials"
-	req, _ := http.NewRequest("POST", authURL, nil)
+// UserLogin returns the login name of the logged in user, if it is known
+func (a *Manager) UserLogin() string {
+	token, _ := a.store.Load(userTokenKey)
+	return token.Login
+}
 
-	var authResponse authResponse
+// HasUserToken reports whether a user access token can be obtained
+func (a *Manager) HasUserToken() bool {
+	token, err := a.store.Load(userTokenKey)
+	return err == nil && token.RefreshToken != ""
+}
 
-	client := http.Client{}
+// GetToken returns an app access token used to make Twitch API requests, fetching a new one shortly before it expires
+func (a *Manager) GetToken(ctx context.Context) (string, error) {
+	a.appMutex.Lock()
+	defer a.appMutex.Unlock()
 
-	res, error := client.Do(req)
+	token, err := a.store.Load(appTokenKey)
+	if err == nil && isTokenFresh(token) {
+		return token.AccessToken, nil
+	}
+
+	form := url.Values{}
+	form.Set("client_id", a.settings.TwitchClientID)
+	form.Set("client_secret", a.settings.TwitchSecret)
+	form.Set("grant_type", "client_credentials")
+
+	authResponse, err := a.requestToken(ctx, form)
+	if err != nil {
+		return "", err
+	}
+
+	token = Token{AccessToken: authResponse.AccessToken, ExpiresAt: expiresAt(authResponse)}
+	if err := a.store.Save(appTokenKey, token); err != nil {
+		fmt.Println("Error saving app token: ", err)
+	}
+	return token.AccessToken, nil
+}
+
+// GetUserToken returns a user access token, using the refresh token to fetch a new one shortly before it expires
+func (a *Manager) GetUserToken(ctx context.Context) (string, error) {
+	a.userMutex.Lock()
+	defer a.userMutex.Unlock()
+
+	token, err := a.store.Load(userTokenKey)
+	if err != nil || token.RefreshToken == "" {
+		return "", ErrNoUserToken
+	}
+	if isTokenFresh(token) {
+		return token.AccessToken, nil
+	}
+
+	form := url.Values{}
+	form.Set("client_id", a.settings.TwitchClientID)
+	form.Set("client_secret", a.settings.TwitchSecret)
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", token.RefreshToken)
+
+	authResponse, err := a.requestToken(ctx, form)
+	if tokenError, ok := err.(*tokenError); ok && tokenError.Status == http.StatusBadRequest {
+		// The refresh token was revoked, such as by disconnecting the app on Twitch, so only logging in again helps
+		fmt.Println("Twitch refused the refresh token, log in again: ", tokenError.Message)
+		if err := a.store.Delete(userTokenKey); err != nil {
+			fmt.Println("Error forgetting Twitch user token: ", err)
+		}
+		return "", ErrNoUserToken
+	}
+	if err != nil {
+		return "", err
+	}
+
+	token, err = a.saveUserToken(ctx, authResponse, token)
+	if err != nil {
+		return "", err
+	}
+	return token.AccessToken, nil
+}
+
+// saveUserToken stores a new user token, validating it to learn the user's ID if previous doesn't know it.
+// The caller must hold userMutex.
+func (a *Manager) saveUserToken(ctx context.Context, authResponse authResponse, previous Token) (Token, error) {
+	token := Token{
+		AccessToken:  authResponse.AccessToken,
+		RefreshToken: authResponse.RefreshToken,
+		ExpiresAt:    expiresAt(authResponse),
+		UserID:       previous.UserID,
+		Login:        previous.Login,
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = previous.RefreshToken
+	}
+
+	if token.UserID == "" {
+		validation, err := a.validate(ctx, token.AccessToken)
+		if err != nil {
+			return token, err
+		}
+		token.UserID = validation.UserID
+		token.Login = validation.Login
+	}
+
+	if err := a.store.Save(userTokenKey, token); err != nil {
+		fmt.Println("Error saving Twitch user token: ", err)
+	}
+	return token, nil
+}
+
+// requestToken posts form to the token endpoint and parses the token in the response
+func (a *Manager) requestToken(ctx context.Context, form url.Values) (authResponse, error) {
+	var authResponse authResponse
+
+	res, error := a.postForm(ctx, "/oauth2/token", form)
 	if error != nil {
 		fmt.Println(error)
-		return "", error
+		return authResponse, error
 	}
 
 	defer res.Body.Close()
 
 	body, error := ioutil.ReadAll(res.Body)
 	if error != nil {
-		return "", errors.New("Error reading auth response")
+		return authResponse, errors.New("Error reading auth response")
+	}
+
+	if res.StatusCode != http.StatusOK {
+		tokenError := tokenError{Status: res.StatusCode, Message: string(body)}
+		json.Unmarshal(body, &tokenError)
+		return authResponse, &tokenError
 	}
 
 	err := json.Unmarshal(body, &authResponse)
 	if err != nil {
-		log.Fatalln("Error parsing the auth JSON response: ", err)
-		return "", errors.New("Error parsing auth response JSON")
+		return authResponse, errors.New("Error parsing auth response JSON")
+	}
+	return authResponse, nil
+}
+
+// postForm posts form to path on the OAuth server
+func (a *Manager) postForm(ctx context.Context, path string, form url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", a.oauthURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
 	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return a.client.Do(req)
+}
 
-	storedAuthResponse = authResponse
-	expiresTime = time.Now().Add(authResponse.ExpiresIn * time.Second)
+// tokenError is the error body Twitch sends when a token request is refused
+type tokenError struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
 
-	return authResponse.AccessToken, nil
+func (e *tokenError) Error() string {
+	return "Error fetching token, got status code " + strconv.Itoa(e.Status) + " " + e.Message
 }
 
-func isSavedTokenValid() bool {
-	if storedAuthResponse.AccessToken != "" && expiresTime.After(time.Now()) {
-		fmt.Println("Valid token")
-		return true
-	}
-	fmt.Println("Invalid token")
-	return false
+func expiresAt(authResponse authResponse) time.Time {
+	return time.Now().Add(authResponse.ExpiresIn * time.Second)
+}
+
+// isTokenFresh reports whether a token can still be used without renewing it first
+func isTokenFresh(token Token) bool {
+	return token.AccessToken != "" && time.Now().Add(renewBefore).Before(token.ExpiresAt)
 }
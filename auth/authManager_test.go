@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestGetTokenReusesFreshToken(t *testing.T) {
+	oauth := newFakeOAuth(t, map[string]http.HandlerFunc{
+		"/oauth2/token": func(w http.ResponseWriter, r *http.Request) {
+			writeTestJSON(w, http.StatusOK, map[string]interface{}{"access_token": "app-token", "expires_in": 3600})
+		},
+	})
+	manager := NewManagerWithURL(testSettings, NewMemoryTokenStore(), oauth.URL)
+
+	for i := 0; i < 3; i++ {
+		token, err := manager.GetToken(context.Background())
+		if err != nil || token != "app-token" {
+			t.Fatalf("GetToken() = %q, %v", token, err)
+		}
+	}
+	forms := oauth.posted("/oauth2/token")
+	if len(forms) != 1 {
+		t.Fatalf("made %d token requests, want 1", len(forms))
+	}
+	if forms[0]["grant_type"] != "client_credentials" || forms[0]["client_id"] != "client-id" || forms[0]["client_secret"] != "secret" {
+		t.Errorf("posted %v", forms[0])
+	}
+}
+
+// Concurrent requests for an app token share a single token request
+func TestGetTokenSingleFlight(t *testing.T) {
+	oauth := newFakeOAuth(t, map[string]http.HandlerFunc{
+		"/oauth2/token": func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(50 * time.Millisecond)
+			writeTestJSON(w, http.StatusOK, map[string]interface{}{"access_token": "app-token", "expires_in": 3600})
+		},
+	})
+	manager := NewManagerWithURL(testSettings, NewMemoryTokenStore(), oauth.URL)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if token, err := manager.GetToken(context.Background()); err != nil || token != "app-token" {
+				t.Errorf("GetToken() = %q, %v", token, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if requests := len(oauth.posted("/oauth2/token")); requests != 1 {
+		t.Errorf("made %d token requests, want 1", requests)
+	}
+}
+
+func TestGetTokenRenewsExpiringToken(t *testing.T) {
+	oauth := newFakeOAuth(t, map[string]http.HandlerFunc{
+		"/oauth2/token": func(w http.ResponseWriter, r *http.Request) {
+			// Expiring within renewBefore means the token is renewed on every call
+			writeTestJSON(w, http.StatusOK, map[string]interface{}{"access_token": "app-token", "expires_in": 60})
+		},
+	})
+	manager := NewManagerWithURL(testSettings, NewMemoryTokenStore(), oauth.URL)
+
+	manager.GetToken(context.Background())
+	manager.GetToken(context.Background())
+	if requests := len(oauth.posted("/oauth2/token")); requests != 2 {
+		t.Errorf("made %d token requests, want 2", requests)
+	}
+}
+
+func TestGetUserTokenRotatesRefreshToken(t *testing.T) {
+	oauth := newFakeOAuth(t, map[string]http.HandlerFunc{
+		"/oauth2/token": func(w http.ResponseWriter, r *http.Request) {
+			writeTestJSON(w, http.StatusOK, map[string]interface{}{"access_token": "user-token", "refresh_token": "rotated-" + r.FormValue("refresh_token"), "expires_in": 60})
+		},
+		"/oauth2/validate": validateHandler,
+	})
+	settings := testSettings
+	settings.TwitchRefreshToken = "configured"
+	store := NewMemoryTokenStore()
+	manager := NewManagerWithURL(settings, store, oauth.URL)
+
+	for i := 0; i < 2; i++ {
+		if token, err := manager.GetUserToken(context.Background()); err != nil || token != "user-token" {
+			t.Fatalf("GetUserToken() = %q, %v", token, err)
+		}
+	}
+	forms := oauth.posted("/oauth2/token")
+	if len(forms) != 2 || forms[0]["refresh_token"] != "configured" || forms[1]["refresh_token"] != "rotated-configured" {
+		t.Errorf("posted %v", forms)
+	}
+	if token, _ := store.Load(userTokenKey); token.RefreshToken != "rotated-rotated-configured" || token.UserID != "42" {
+		t.Errorf("saved %+v", token)
+	}
+}
+
+func TestGetUserTokenRevokedRefreshToken(t *testing.T) {
+	oauth := newFakeOAuth(t, map[string]http.HandlerFunc{
+		"/oauth2/token": func(w http.ResponseWriter, r *http.Request) {
+			writeTestJSON(w, http.StatusBadRequest, map[string]interface{}{"status": 400, "message": "Invalid refresh token"})
+		},
+	})
+	store := NewMemoryTokenStore()
+	store.Save(userTokenKey, Token{AccessToken: "expired", RefreshToken: "revoked", UserID: "42"})
+	manager := NewManagerWithURL(testSettings, store, oauth.URL)
+
+	if _, err := manager.GetUserToken(context.Background()); err != ErrNoUserToken {
+		t.Errorf("error = %v, want ErrNoUserToken", err)
+	}
+	if manager.HasUserToken() {
+		t.Error("still has a user token after the refresh token was refused")
+	}
+	if _, err := store.Load(userTokenKey); err != ErrTokenNotFound {
+		t.Errorf("Load() error = %v, want the token forgotten", err)
+	}
+
+	// Without a token there is nothing to refresh, so Twitch isn't asked again
+	manager.GetUserToken(context.Background())
+	if requests := len(oauth.posted("/oauth2/token")); requests != 1 {
+		t.Errorf("made %d token requests, want 1", requests)
+	}
+}
+
+func TestGetUserTokenWithoutLogin(t *testing.T) {
+	manager := NewManagerWithURL(testSettings, NewMemoryTokenStore(), "http://127.0.0.1:0")
+	if _, err := manager.GetUserToken(context.Background()); err != ErrNoUserToken {
+		t.Errorf("error = %v, want ErrNoUserToken", err)
+	}
+}
+
+func TestRequestsTimeOut(t *testing.T) {
+	hang := func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}
+	oauth := newFakeOAuth(t, map[string]http.HandlerFunc{
+		"/oauth2/token": func(w http.ResponseWriter, r *http.Request) {
+			if r.FormValue("grant_type") == "authorization_code" {
+				writeTestJSON(w, http.StatusOK, map[string]interface{}{"access_token": "user-token", "refresh_token": "refresh-token", "expires_in": 3600})
+				return
+			}
+			hang(w, r)
+		},
+		"/oauth2/device":   hang,
+		"/oauth2/validate": hang,
+	})
+	manager := NewManagerWithClient(testSettings, NewMemoryTokenStore(), &http.Client{Timeout: 50 * time.Millisecond}, oauth.URL)
+
+	calls := map[string]func() error{
+		"token": func() error {
+			_, err := manager.GetToken(context.Background())
+			return err
+		},
+		"device": func() error {
+			_, err := manager.StartDeviceAuthorization(context.Background())
+			return err
+		},
+		"validate": func() error {
+			return manager.ExchangeCode(context.Background(), "code", "http://localhost:8080/auth/callback")
+		},
+	}
+	for name, call := range calls {
+		start := time.Now()
+		err := call()
+		if err == nil || !strings.Contains(err.Error(), "Timeout") {
+			t.Errorf("%s error = %v, want a timeout", name, err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("%s took %v", name, elapsed)
+		}
+	}
+}
+
+func TestRequestsStopWhenCancelled(t *testing.T) {
+	oauth := newFakeOAuth(t, map[string]http.HandlerFunc{
+		"/oauth2/token": func(w http.ResponseWriter, r *http.Request) {
+			<-r.Context().Done()
+		},
+	})
+	manager := NewManagerWithURL(testSettings, NewMemoryTokenStore(), oauth.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := manager.GetToken(ctx); err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Errorf("error = %v, want the context's deadline", err)
+	}
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// Twitch gives device code intervals and expiry in seconds, tests shorten this to keep polling fast
+var deviceTimeUnit = time.Second
+
+// Polling waits this many units longer every time Twitch answers slow_down
+const slowDownIncrease = 5
+
+// DeviceAuthorization is a pending device code login, the user enters UserCode at VerificationURI to approve it
+type DeviceAuthorization struct {
+	DeviceCode      string `json:"device_code"`
+	UserCode        string `json:"user_code"`
+	VerificationURI string `json:"verification_uri"`
+	ExpiresIn       int    `json:"expires_in"`
+	Interval        int    `json:"interval"`
+}
+
+// StartDeviceAuthorization begins a device code login for installs without a browser
+func (a *Manager) StartDeviceAuthorization(ctx context.Context) (DeviceAuthorization, error) {
+	var authorization DeviceAuthorization
+
+	form := url.Values{}
+	form.Set("client_id", a.settings.TwitchClientID)
+	form.Set("scopes", userScopes)
+
+	res, err := a.postForm(ctx, "/oauth2/device", form)
+	if err != nil {
+		return authorization, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return authorization, errors.New("Error reading device authorization response")
+	}
+	if res.StatusCode != http.StatusOK {
+		return authorization, errors.New("Error starting device authorization, got status code " + strconv.Itoa(res.StatusCode) + " " + string(body))
+	}
+
+	if err := json.Unmarshal(body, &authorization); err != nil {
+		return authorization, errors.New("Error parsing device authorization JSON")
+	}
+	return authorization, nil
+}
+
+// WaitForDeviceAuthorization polls until the user approves the login, then stores the user's tokens
+func (a *Manager) WaitForDeviceAuthorization(ctx context.Context, authorization DeviceAuthorization) error {
+	interval := time.Duration(authorization.Interval) * deviceTimeUnit
+	if interval <= 0 {
+		interval = 5 * deviceTimeUnit
+	}
+	expiresIn := time.Duration(authorization.ExpiresIn) * deviceTimeUnit
+	if expiresIn <= 0 {
+		expiresIn = 1800 * deviceTimeUnit
+	}
+	ctx, cancel := context.WithTimeout(ctx, expiresIn)
+	defer cancel()
+
+	form := url.Values{}
+	form.Set("client_id", a.settings.TwitchClientID)
+	form.Set("scopes", userScopes)
+	form.Set("device_code", authorization.DeviceCode)
+	form.Set("grant_type", deviceCodeGrantType)
+
+	expired := errors.New("Device authorization expired before it was approved")
+	for {
+		select {
+		case <-ctx.Done():
+			return expired
+		case <-time.After(interval):
+		}
+
+		authResponse, err := a.requestToken(ctx, form)
+		// The authorization can also expire while a poll is on its way
+		if err != nil && ctx.Err() == context.DeadlineExceeded {
+			return expired
+		}
+		if tokenError, ok := err.(*tokenError); ok {
+			switch tokenError.Message {
+			case "authorization_pending":
+				continue
+			case "slow_down":
+				interval += slowDownIncrease * deviceTimeUnit
+				continue
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		a.userMutex.Lock()
+		defer a.userMutex.Unlock()
+
+		_, err = a.saveUserToken(ctx, authResponse, Token{})
+		return err
+	}
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useShortDeviceTimeUnit makes device code seconds last 10ms for the rest of the test
+func useShortDeviceTimeUnit(t *testing.T) {
+	deviceTimeUnit = 10 * time.Millisecond
+	t.Cleanup(func() { deviceTimeUnit = time.Second })
+}
+
+// deviceTokenHandler answers each token poll with the next of replies, an empty reply approves the login
+func deviceTokenHandler(replies []string, polledAt *[]time.Time) http.HandlerFunc {
+	var mutex sync.Mutex
+	return func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		*polledAt = append(*polledAt, time.Now())
+		reply := replies[len(replies)-1]
+		if len(*polledAt) <= len(replies) {
+			reply = replies[len(*polledAt)-1]
+		}
+		if reply != "" {
+			writeTestJSON(w, http.StatusBadRequest, map[string]interface{}{"status": 400, "message": reply})
+			return
+		}
+		writeTestJSON(w, http.StatusOK, map[string]interface{}{"access_token": "user-token", "refresh_token": "refresh-token", "expires_in": 3600})
+	}
+}
+
+func TestDeviceAuthorization(t *testing.T) {
+	useShortDeviceTimeUnit(t)
+	var polledAt []time.Time
+	oauth := newFakeOAuth(t, map[string]http.HandlerFunc{
+		"/oauth2/device": func(w http.ResponseWriter, r *http.Request) {
+			writeTestJSON(w, http.StatusOK, map[string]interface{}{
+				"device_code": "device-code", "user_code": "ABCDEFGH", "verification_uri": "https://www.twitch.tv/activate", "expires_in": 1800, "interval": 1,
+			})
+		},
+		"/oauth2/token":    deviceTokenHandler([]string{"authorization_pending", "slow_down", "authorization_pending", ""}, &polledAt),
+		"/oauth2/validate": validateHandler,
+	})
+	manager := NewManagerWithURL(testSettings, NewMemoryTokenStore(), oauth.URL)
+
+	authorization, err := manager.StartDeviceAuthorization(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if authorization.UserCode != "ABCDEFGH" || authorization.VerificationURI != "https://www.twitch.tv/activate" {
+		t.Errorf("authorization = %+v", authorization)
+	}
+	if form := oauth.posted("/oauth2/device")[0]; form["client_id"] != "client-id" || form["scopes"] != userScopes {
+		t.Errorf("posted %v", form)
+	}
+
+	if err := manager.WaitForDeviceAuthorization(context.Background(), authorization); err != nil {
+		t.Fatal(err)
+	}
+	if len(polledAt) != 4 {
+		t.Fatalf("polled %d times, want 4", len(polledAt))
+	}
+	for _, form := range oauth.posted("/oauth2/token") {
+		if form["device_code"] != "device-code" || form["grant_type"] != deviceCodeGrantType {
+			t.Errorf("polled with %v", form)
+		}
+	}
+	// After slow_down the interval grows from 1 to 6 units
+	if gap := polledAt[2].Sub(polledAt[1]); gap < 6*deviceTimeUnit {
+		t.Errorf("polled %v after slow_down, want at least %v", gap, 6*deviceTimeUnit)
+	}
+	if manager.UserID() != "42" || manager.UserLogin() != "someone" {
+		t.Errorf("logged in as %q (%q)", manager.UserID(), manager.UserLogin())
+	}
+}
+
+func TestDeviceAuthorizationExpires(t *testing.T) {
+	useShortDeviceTimeUnit(t)
+	var polledAt []time.Time
+	oauth := newFakeOAuth(t, map[string]http.HandlerFunc{
+		"/oauth2/token": deviceTokenHandler([]string{"authorization_pending"}, &polledAt),
+	})
+	manager := NewManagerWithURL(testSettings, NewMemoryTokenStore(), oauth.URL)
+
+	err := manager.WaitForDeviceAuthorization(context.Background(), DeviceAuthorization{DeviceCode: "device-code", ExpiresIn: 5, Interval: 1})
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("error = %v, want the authorization to expire", err)
+	}
+	if manager.HasUserToken() {
+		t.Error("has a user token after the authorization expired")
+	}
+}
+
+func TestDeviceAuthorizationExpiresDuringPoll(t *testing.T) {
+	useShortDeviceTimeUnit(t)
+	oauth := newFakeOAuth(t, map[string]http.HandlerFunc{
+		// Twitch doesn't answer until the authorization has expired
+		"/oauth2/token": func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		},
+	})
+	manager := NewManagerWithURL(testSettings, NewMemoryTokenStore(), oauth.URL)
+
+	err := manager.WaitForDeviceAuthorization(context.Background(), DeviceAuthorization{DeviceCode: "device-code", ExpiresIn: 5, Interval: 1})
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Errorf("error = %v, want the authorization to expire", err)
+	}
+}
+
+func TestDeviceAuthorizationDenied(t *testing.T) {
+	useShortDeviceTimeUnit(t)
+	var polledAt []time.Time
+	oauth := newFakeOAuth(t, map[string]http.HandlerFunc{
+		"/oauth2/token": deviceTokenHandler([]string{"authorization_pending", "authorization_denied"}, &polledAt),
+	})
+	manager := NewManagerWithURL(testSettings, NewMemoryTokenStore(), oauth.URL)
+
+	err := manager.WaitForDeviceAuthorization(context.Background(), DeviceAuthorization{DeviceCode: "device-code", ExpiresIn: 1800, Interval: 1})
+	if err == nil || !strings.Contains(err.Error(), "authorization_denied") {
+		t.Errorf("error = %v, want the denial", err)
+	}
+	if len(polledAt) != 2 {
+		t.Errorf("polled %d times, want polling to stop once denied", len(polledAt))
+	}
+}
@@ -0,0 +1,42 @@
+package auth
+
+import (
+	"context"
+	"net/url"
+)
+
+// Scopes requested when a user logs in
+const userScopes = "user:read:follows"
+
+// AuthorizeURL returns the Twitch page that asks the user to grant access, Twitch redirects back to redirectURI with a code
+func (a *Manager) AuthorizeURL(redirectURI string, state string) string {
+	queryParameters := url.Values{}
+	queryParameters.Set("client_id", a.settings.TwitchClientID)
+	queryParameters.Set("redirect_uri", redirectURI)
+	queryParameters.Set("response_type", "code")
+	queryParameters.Set("scope", userScopes)
+	queryParameters.Set("state", state)
+	return a.oauthURL + "/oauth2/authorize?" + queryParameters.Encode()
+}
+
+// ExchangeCode trades an authorization code for user access and refresh tokens and learns who logged in
+func (a *Manager) ExchangeCode(ctx context.Context, code string, redirectURI string) error {
+	form := url.Values{}
+	form.Set("client_id", a.settings.TwitchClientID)
+	form.Set("client_secret", a.settings.TwitchSecret)
+	form.Set("code", code)
+	form.Set("grant_type", "authorization_code")
+	form.Set("redirect_uri", redirectURI)
+
+	authResponse, err := a.requestToken(ctx, form)
+	if err != nil {
+		return err
+	}
+
+	a.userMutex.Lock()
+	defer a.userMutex.Unlock()
+
+	// A different account may be logging in, so always look the user up again
+	_, err = a.saveUserToken(ctx, authResponse, Token{})
+	return err
+}
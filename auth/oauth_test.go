@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"twitch-caster/models"
+)
+
+// fakeOAuth serves the Twitch OAuth endpoints from handlers by path, recording the forms posted to it
+type fakeOAuth struct {
+	*httptest.Server
+	mutex sync.Mutex
+	forms map[string][]map[string]string
+}
+
+func newFakeOAuth(t *testing.T, handlers map[string]http.HandlerFunc) *fakeOAuth {
+	oauth := &fakeOAuth{forms: make(map[string][]map[string]string)}
+	oauth.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		form := make(map[string]string)
+		for key := range r.PostForm {
+			form[key] = r.PostForm.Get(key)
+		}
+		oauth.mutex.Lock()
+		oauth.forms[r.URL.Path] = append(oauth.forms[r.URL.Path], form)
+		oauth.mutex.Unlock()
+
+		handler, ok := handlers[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(oauth.Close)
+	return oauth
+}
+
+func (f *fakeOAuth) posted(path string) []map[string]string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]map[string]string(nil), f.forms[path]...)
+}
+
+func writeTestJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// validateHandler answers validation of user-token as user 42, and rejects every other token
+func validateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "OAuth user-token" {
+		writeTestJSON(w, http.StatusUnauthorized, map[string]interface{}{"status": 401, "message": "invalid access token"})
+		return
+	}
+	writeTestJSON(w, http.StatusOK, map[string]interface{}{"client_id": "client-id", "login": "someone", "user_id": "42", "expires_in": 3600})
+}
+
+var testSettings = models.Settings{TwitchClientID: "client-id", TwitchSecret: "secret"}
+
+func TestExchangeCode(t *testing.T) {
+	oauth := newFakeOAuth(t, map[string]http.HandlerFunc{
+		"/oauth2/token": func(w http.ResponseWriter, r *http.Request) {
+			if r.FormValue("code") != "good-code" {
+				writeTestJSON(w, http.StatusBadRequest, map[string]interface{}{"status": 400, "message": "Invalid authorization code"})
+				return
+			}
+			writeTestJSON(w, http.StatusOK, map[string]interface{}{"access_token": "user-token", "refresh_token": "refresh-token", "expires_in": 3600})
+		},
+		"/oauth2/validate": validateHandler,
+	})
+	manager := NewManagerWithURL(testSettings, NewMemoryTokenStore(), oauth.URL+"/")
+
+	if manager.HasUserToken() {
+		t.Fatal("has a user token before logging in")
+	}
+	if err := manager.ExchangeCode(context.Background(), "good-code", "http://localhost:8080/auth/callback"); err != nil {
+		t.Fatal(err)
+	}
+
+	form := oauth.posted("/oauth2/token")[0]
+	want := map[string]string{
+		"client_id":     "client-id",
+		"client_secret": "secret",
+		"code":          "good-code",
+		"grant_type":    "authorization_code",
+		"redirect_uri":  "http://localhost:8080/auth/callback",
+	}
+	for key, value := range want {
+		if form[key] != value {
+			t.Errorf("posted %s = %q, want %q", key, form[key], value)
+		}
+	}
+
+	if manager.UserID() != "42" || manager.UserLogin() != "someone" || !manager.HasUserToken() {
+		t.Errorf("logged in as %q (%q), has user token %v", manager.UserID(), manager.UserLogin(), manager.HasUserToken())
+	}
+	token, err := manager.GetUserToken(context.Background())
+	if err != nil || token != "user-token" {
+		t.Errorf("GetUserToken() = %q, %v", token, err)
+	}
+	if requests := len(oauth.posted("/oauth2/token")); requests != 1 {
+		t.Errorf("made %d token requests, want the fresh token to be reused", requests)
+	}
+}
+
+// A configured userId is only used until somebody logs in, who may be a different account
+func TestExchangeCodeReplacesConfiguredUserID(t *testing.T) {
+	oauth := newFakeOAuth(t, map[string]http.HandlerFunc{
+		"/oauth2/token": func(w http.ResponseWriter, r *http.Request) {
+			writeTestJSON(w, http.StatusOK, map[string]interface{}{"access_token": "user-token", "refresh_token": "refresh-token", "expires_in": 3600})
+		},
+		"/oauth2/validate": validateHandler,
+	})
+	settings := testSettings
+	settings.UserID = "123456"
+	manager := NewManagerWithURL(settings, NewMemoryTokenStore(), oauth.URL)
+
+	if userID := manager.UserID(); userID != "123456" {
+		t.Errorf("UserID() before logging in = %q, want the configured one", userID)
+	}
+	if err := manager.ExchangeCode(context.Background(), "good-code", "http://localhost:8080/auth/callback"); err != nil {
+		t.Fatal(err)
+	}
+	if userID := manager.UserID(); userID != "42" {
+		t.Errorf("UserID() = %q, want the logged in user", userID)
+	}
+}
+
+func TestExchangeCodeRefused(t *testing.T) {
+	oauth := newFakeOAuth(t, map[string]http.HandlerFunc{
+		"/oauth2/token": func(w http.ResponseWriter, r *http.Request) {
+			writeTestJSON(w, http.StatusBadRequest, map[string]interface{}{"status": 400, "message": "Invalid authorization code"})
+		},
+	})
+	manager := NewManagerWithURL(testSettings, NewMemoryTokenStore(), oauth.URL)
+
+	err := manager.ExchangeCode(context.Background(), "bad-code", "http://localhost:8080/auth/callback")
+	if err == nil || !strings.Contains(err.Error(), "400 Invalid authorization code") {
+		t.Errorf("error = %v, want the refusal", err)
+	}
+	if manager.HasUserToken() {
+		t.Error("has a user token after a refused login")
+	}
+}
+
+func TestExchangeCodeValidationFails(t *testing.T) {
+	oauth := newFakeOAuth(t, map[string]http.HandlerFunc{
+		"/oauth2/token": func(w http.ResponseWriter, r *http.Request) {
+			writeTestJSON(w, http.StatusOK, map[string]interface{}{"access_token": "revoked-token", "refresh_token": "refresh-token", "expires_in": 3600})
+		},
+		"/oauth2/validate": validateHandler,
+	})
+	manager := NewManagerWithURL(testSettings, NewMemoryTokenStore(), oauth.URL)
+
+	if err := manager.ExchangeCode(context.Background(), "good-code", "http://localhost:8080/auth/callback"); err == nil {
+		t.Error("expected an error when the new token doesn't validate")
+	}
+	if manager.UserID() != "" {
+		t.Errorf("UserID() = %q, want no user", manager.UserID())
+	}
+}
+
+func TestAuthorizeURL(t *testing.T) {
+	manager := NewManagerWithURL(testSettings, NewMemoryTokenStore(), "https://id.example.com/")
+	got := manager.AuthorizeURL("http://localhost:8080/auth/callback", "state")
+	want := "https://id.example.com/oauth2/authorize?client_id=client-id&redirect_uri=http%3A%2F%2Flocalhost%3A8080%2Fauth%2Fcallback&response_type=code&scope=user%3Aread%3Afollows&state=state"
+	if got != want {
+		t.Errorf("AuthorizeURL() = %s, want %s", got, want)
+	}
+}
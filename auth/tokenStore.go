@@ -0,0 +1,206 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrTokenNotFound is returned by a TokenStore that has no token saved under a key
+var ErrTokenNotFound = errors.New("Token not found")
+
+// Keys the Manager saves its tokens under
+const (
+	appTokenKey  = "app"
+	userTokenKey = "user"
+)
+
+// Token is a saved access token along with what is needed to renew it
+type Token struct {
+	AccessToken  string    `json:"accessToken"`
+	RefreshToken string    `json:"refreshToken"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	UserID       string    `json:"userId,omitempty"`
+	Login        string    `json:"login,omitempty"`
+}
+
+// TokenStore saves tokens by key
+type TokenStore interface {
+	Load(key string) (Token, error)
+	Save(key string, token Token) error
+	Delete(key string) error
+}
+
+// MemoryTokenStore keeps tokens in memory, they are lost on restart
+type MemoryTokenStore struct {
+	mutex  sync.Mutex
+	tokens map[string]Token
+}
+
+// NewMemoryTokenStore creates a new MemoryTokenStore object
+func NewMemoryTokenStore() *MemoryTokenStore {
+	store := MemoryTokenStore{}
+	store.tokens = make(map[string]Token)
+	return &store
+}
+
+// Load returns the token saved under key
+func (m *MemoryTokenStore) Load(key string) (Token, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	token, ok := m.tokens[key]
+	if !ok {
+		return Token{}, ErrTokenNotFound
+	}
+	return token, nil
+}
+
+// Save saves token under key
+func (m *MemoryTokenStore) Save(key string, token Token) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.tokens[key] = token
+	return nil
+}
+
+// Delete removes the token saved under key
+func (m *MemoryTokenStore) Delete(key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.tokens, key)
+	return nil
+}
+
+// EncryptedFileTokenStore keeps tokens in a file encrypted with AES-GCM so they survive restarts
+type EncryptedFileTokenStore struct {
+	mutex  sync.Mutex
+	path   string
+	cipher cipher.AEAD
+}
+
+// NewEncryptedFileTokenStore creates a store at path, encrypted with a key derived from secret
+func NewEncryptedFileTokenStore(path string, secret string) (*EncryptedFileTokenStore, error) {
+	key := sha256.Sum256([]byte("twitch-caster token store " + secret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	store := EncryptedFileTokenStore{}
+	store.path = path
+	store.cipher = aead
+
+	// Fail now rather than on the first request if the file can't be read, such as after twitchSecret changed
+	if _, err := store.read(); err != nil {
+		return nil, err
+	}
+	return &store, nil
+}
+
+// Load returns the token saved under key
+func (e *EncryptedFileTokenStore) Load(key string) (Token, error) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	tokens, err := e.read()
+	if err != nil {
+		return Token{}, err
+	}
+	token, ok := tokens[key]
+	if !ok {
+		return Token{}, ErrTokenNotFound
+	}
+	return token, nil
+}
+
+// Save saves token under key
+func (e *EncryptedFileTokenStore) Save(key string, token Token) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	tokens, err := e.read()
+	if err != nil {
+		return err
+	}
+	tokens[key] = token
+	return e.write(tokens)
+}
+
+// Delete removes the token saved under key
+func (e *EncryptedFileTokenStore) Delete(key string) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	tokens, err := e.read()
+	if err != nil {
+		return err
+	}
+	delete(tokens, key)
+	return e.write(tokens)
+}
+
+func (e *EncryptedFileTokenStore) read() (map[string]Token, error) {
+	tokens := make(map[string]Token)
+
+	data, err := ioutil.ReadFile(e.path)
+	if os.IsNotExist(err) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nonceSize := e.cipher.NonceSize()
+	if len(data) < nonceSize {
+		return nil, errors.New("Token file " + e.path + " is corrupt")
+	}
+	plaintext, err := e.cipher.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return nil, errors.New("Unable to decrypt token file " + e.path + ", was twitchSecret changed? Delete the file and log in again")
+	}
+
+	if err := json.Unmarshal(plaintext, &tokens); err != nil {
+		return nil, errors.New("Error parsing token file JSON")
+	}
+	return tokens, nil
+}
+
+func (e *EncryptedFileTokenStore) write(tokens map[string]Token) error {
+	plaintext, _ := json.Marshal(tokens)
+
+	nonce := make([]byte, e.cipher.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	// Write a temporary file and rename it over the old one, so a crash part way through never leaves a corrupt file
+	file, err := ioutil.TempFile(filepath.Dir(e.path), filepath.Base(e.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(e.cipher.Seal(nonce, nonce, plaintext, nil))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), e.path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+	}
+	return err
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptedFileTokenStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.dat")
+	store, err := NewEncryptedFileTokenStore(path, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(appTokenKey); err != ErrTokenNotFound {
+		t.Errorf("Load() from a new store error = %v, want ErrTokenNotFound", err)
+	}
+	if err := store.Save(userTokenKey, Token{AccessToken: "user-token", RefreshToken: "refresh-token", UserID: "42"}); err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	if strings.Contains(string(data), "refresh-token") {
+		t.Error("token file is not encrypted")
+	}
+
+	reopened, err := NewEncryptedFileTokenStore(path, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := reopened.Load(userTokenKey)
+	if err != nil || token.RefreshToken != "refresh-token" || token.UserID != "42" {
+		t.Errorf("Load() = %+v, %v", token, err)
+	}
+
+	if err := reopened.Delete(userTokenKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := reopened.Load(userTokenKey); err != ErrTokenNotFound {
+		t.Errorf("Load() after Delete() error = %v, want ErrTokenNotFound", err)
+	}
+}
+
+func TestEncryptedFileTokenStoreUnreadable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.dat")
+	store, _ := NewEncryptedFileTokenStore(path, "secret")
+	store.Save(userTokenKey, Token{RefreshToken: "refresh-token"})
+
+	if _, err := NewEncryptedFileTokenStore(path, "changed"); err == nil || !strings.Contains(err.Error(), "Unable to decrypt token file") {
+		t.Errorf("opening with a changed secret error = %v", err)
+	}
+
+	ioutil.WriteFile(path, []byte("short"), 0600)
+	if _, err := NewEncryptedFileTokenStore(path, "secret"); err == nil || !strings.Contains(err.Error(), "is corrupt") {
+		t.Errorf("opening a truncated file error = %v", err)
+	}
+}
+
+func TestEncryptedFileTokenStoreWritesAtomically(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "tokens.dat")
+	store, _ := NewEncryptedFileTokenStore(path, "secret")
+	for _, refreshToken := range []string{"first", "second"} {
+		if err := store.Save(userTokenKey, Token{RefreshToken: refreshToken}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The temporary file was renamed into place
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 || files[0].Name() != "tokens.dat" || files[0].Mode().Perm() != 0600 {
+		t.Fatalf("directory has %v", files)
+	}
+
+	// A write that can't be renamed into place removes its temporary file
+	os.Rename(path, path+".saved")
+	os.MkdirAll(filepath.Join(path, "in-the-way"), 0700)
+	if err := store.write(map[string]Token{userTokenKey: {RefreshToken: "third"}}); err == nil {
+		t.Fatal("write() over a directory succeeded")
+	}
+	files, _ = ioutil.ReadDir(dir)
+	if len(files) != 2 {
+		t.Errorf("directory has %d files, want the temporary file removed", len(files))
+	}
+	os.RemoveAll(path)
+	os.Rename(path+".saved", path)
+	if token, err := store.Load(userTokenKey); err != nil || token.RefreshToken != "second" {
+		t.Errorf("Load() = %+v, %v", token, err)
+	}
+}
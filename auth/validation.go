@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// Twitch requires apps to validate their tokens at least once an hour
+const validationInterval = time.Hour
+
+type validateResponse struct {
+	ClientID  string   `json:"client_id"`
+	Login     string   `json:"login"`
+	UserID    string   `json:"user_id"`
+	Scopes    []string `json:"scopes"`
+	ExpiresIn int      `json:"expires_in"`
+}
+
+// InvalidateAppToken forgets the app token after Twitch has rejected accessToken, so the next request fetches a new one.
+// Nothing is forgotten if the token has already been replaced, such as by a concurrent request that was also rejected.
+func (a *Manager) InvalidateAppToken(accessToken string) {
+	a.appMutex.Lock()
+	defer a.appMutex.Unlock()
+
+	appToken, err := a.store.Load(appTokenKey)
+	if err == nil && appToken.AccessToken == accessToken {
+		a.store.Delete(appTokenKey)
+	}
+}
+
+// InvalidateUserToken forgets the user access token after Twitch has rejected accessToken, keeping the refresh token.
+// Nothing is forgotten if the token has already been replaced.
+func (a *Manager) InvalidateUserToken(accessToken string) {
+	a.userMutex.Lock()
+	defer a.userMutex.Unlock()
+
+	userToken, err := a.store.Load(userTokenKey)
+	if err == nil && userToken.AccessToken != "" && userToken.AccessToken == accessToken {
+		// Keep the refresh token so a new access token can be fetched without logging in again
+		userToken.AccessToken = ""
+		a.store.Save(userTokenKey, userToken)
+	}
+}
+
+// StartValidation validates the saved tokens now and then every hour until ctx is cancelled, forgetting any Twitch has revoked
+func (a *Manager) StartValidation(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(validationInterval)
+		defer ticker.Stop()
+
+		for {
+			a.validateSavedTokens(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (a *Manager) validateSavedTokens(ctx context.Context) {
+	for _, key := range []string{appTokenKey, userTokenKey} {
+		token, err := a.store.Load(key)
+		if err != nil || token.AccessToken == "" {
+			continue
+		}
+
+		_, err = a.validate(ctx, token.AccessToken)
+		if tokenError, ok := err.(*tokenError); ok && tokenError.Status == http.StatusUnauthorized {
+			fmt.Println("Twitch revoked the " + key + " token")
+			if key == userTokenKey {
+				// An expired access token is rejected too, refreshing tells whether the login was revoked and forgets it if so
+				a.InvalidateUserToken(token.AccessToken)
+				if _, err := a.GetUserToken(ctx); err != nil && err != ErrNoUserToken {
+					fmt.Println("Error refreshing the "+key+" token: ", err)
+				}
+			} else {
+				a.InvalidateAppToken(token.AccessToken)
+			}
+		} else if err != nil {
+			fmt.Println("Error validating the "+key+" token: ", err)
+		}
+	}
+}
+
+func (a *Manager) validate(ctx context.Context, accessToken string) (validateResponse, error) {
+	var validation validateResponse
+
+	req, err := http.NewRequestWithContext(ctx, "GET", a.oauthURL+"/oauth2/validate", nil)
+	if err != nil {
+		return validation, err
+	}
+	req.Header.Set("Authorization", "OAuth "+accessToken)
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return validation, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return validation, errors.New("Error reading validate response")
+	}
+	if res.StatusCode != http.StatusOK {
+		tokenError := tokenError{Status: res.StatusCode, Message: string(body)}
+		json.Unmarshal(body, &tokenError)
+		return validation, &tokenError
+	}
+
+	if err := json.Unmarshal(body, &validation); err != nil {
+		return validation, errors.New("Error parsing validate response JSON")
+	}
+	return validation, nil
+}
@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestInvalidateUserTokenKeepsReplacedToken(t *testing.T) {
+	store := NewMemoryTokenStore()
+	store.Save(userTokenKey, Token{AccessToken: "new", RefreshToken: "refresh-token"})
+	manager := NewManagerWithURL(testSettings, store, "http://127.0.0.1:0")
+
+	manager.InvalidateUserToken("old")
+	if token, _ := store.Load(userTokenKey); token.AccessToken != "new" {
+		t.Errorf("a stale rejection forgot the replacement token")
+	}
+
+	manager.InvalidateUserToken("new")
+	token, _ := store.Load(userTokenKey)
+	if token.AccessToken != "" || token.RefreshToken != "refresh-token" {
+		t.Errorf("saved %+v, want only the access token forgotten", token)
+	}
+}
+
+func TestInvalidateAppTokenKeepsReplacedToken(t *testing.T) {
+	store := NewMemoryTokenStore()
+	store.Save(appTokenKey, Token{AccessToken: "new"})
+	manager := NewManagerWithURL(testSettings, store, "http://127.0.0.1:0")
+
+	manager.InvalidateAppToken("old")
+	if _, err := store.Load(appTokenKey); err != nil {
+		t.Errorf("a stale rejection forgot the replacement token")
+	}
+	manager.InvalidateAppToken("new")
+	if _, err := store.Load(appTokenKey); err != ErrTokenNotFound {
+		t.Errorf("Load() error = %v, want the token forgotten", err)
+	}
+}
+
+func TestStartValidationValidatesImmediately(t *testing.T) {
+	oauth := newFakeOAuth(t, map[string]http.HandlerFunc{
+		"/oauth2/validate": validateHandler,
+	})
+	store := NewMemoryTokenStore()
+	store.Save(appTokenKey, Token{AccessToken: "revoked-token", ExpiresAt: time.Now().Add(time.Hour)})
+	store.Save(userTokenKey, Token{AccessToken: "user-token", RefreshToken: "refresh-token", ExpiresAt: time.Now().Add(time.Hour)})
+	manager := NewManagerWithURL(testSettings, store, oauth.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	manager.StartValidation(ctx)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := store.Load(appTokenKey); err == ErrTokenNotFound {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the revoked app token was not forgotten at startup")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if token, _ := store.Load(userTokenKey); token.AccessToken != "user-token" {
+		t.Errorf("the valid user token was forgotten")
+	}
+}
+
+func TestValidationRefreshesRejectedUserToken(t *testing.T) {
+	tests := []struct {
+		name         string
+		refreshToken string
+		want         string
+	}{
+		{"expired", "refresh-token", "user-token"},
+		{"revoked", "revoked", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			oauth := newFakeOAuth(t, map[string]http.HandlerFunc{
+				"/oauth2/token": func(w http.ResponseWriter, r *http.Request) {
+					if r.FormValue("refresh_token") == "revoked" {
+						writeTestJSON(w, http.StatusBadRequest, map[string]interface{}{"status": 400, "message": "Invalid refresh token"})
+						return
+					}
+					writeTestJSON(w, http.StatusOK, map[string]interface{}{"access_token": "user-token", "expires_in": 3600})
+				},
+				"/oauth2/validate": validateHandler,
+			})
+			store := NewMemoryTokenStore()
+			store.Save(userTokenKey, Token{AccessToken: "rejected", RefreshToken: test.refreshToken, ExpiresAt: time.Now().Add(time.Hour), UserID: "42"})
+			manager := NewManagerWithURL(testSettings, store, oauth.URL)
+
+			manager.validateSavedTokens(context.Background())
+			token, _ := store.Load(userTokenKey)
+			if token.AccessToken != test.want {
+				t.Errorf("access token = %q, want %q", token.AccessToken, test.want)
+			}
+			if hasUserToken := manager.HasUserToken(); hasUserToken != (test.want != "") {
+				t.Errorf("HasUserToken() = %v", hasUserToken)
+			}
+		})
+	}
+}
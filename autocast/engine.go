@@ -0,0 +1,230 @@
+// Package autocast casts streamers automatically when they go live, following rules from configuration.json
+package autocast
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"twitch-caster/cast"
+	"twitch-caster/models"
+	"twitch-caster/streams"
+)
+
+const autoCastPollInterval = 60 * time.Second
+
+// Clock tells the time, so rules can be evaluated at any time of day
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is the Clock of the machine the server runs on
+type SystemClock struct{}
+
+// Now returns the current local time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// LiveChecker reports which streamers, by login name, are live
+type LiveChecker interface {
+	FetchLiveLogins(ctx context.Context, logins []string) (map[string]bool, error)
+}
+
+// Caster starts casting a stream to a Chromecast and reports how each cast went
+type Caster interface {
+	StartCast(streamID string, chromecast models.Chromecast) (cast.Job, error)
+	CastJob(id string) (cast.Job, bool)
+}
+
+// StatusSource returns the last known status of every configured Chromecast
+type StatusSource interface {
+	Statuses() []cast.DeviceStatus
+}
+
+// rule is an AutoCastRule resolved against the configured devices
+type rule struct {
+	streamer    string
+	chromecast  models.Chromecast
+	hours       Hours
+	onlyIfIdle  bool
+	description string
+}
+
+// Engine evaluates the auto cast rules whenever it polls
+type Engine struct {
+	rules    []rule
+	dryRun   bool
+	live     LiveChecker
+	caster   Caster
+	statuses StatusSource
+	clock    Clock
+
+	mutex sync.Mutex
+	// fired holds the rules that have cast their streamer since the streamer went live, so each broadcast is only cast once
+	fired map[int]bool
+	// jobs holds the cast jobs of rules that haven't started playing yet, a rule whose job fails fires again
+	jobs map[int]string
+}
+
+// NewEngine creates an Engine for the configured rules, returning an error if a rule is invalid
+func NewEngine(config models.Configuration, live LiveChecker, caster Caster, statuses StatusSource, clock Clock) (*Engine, error) {
+	engine := Engine{}
+	engine.dryRun = config.AutoCast.DryRun
+	engine.live = live
+	engine.caster = caster
+	engine.statuses = statuses
+	engine.clock = clock
+	engine.fired = make(map[int]bool)
+	engine.jobs = make(map[int]string)
+
+	for i, autoCastRule := range config.AutoCast.Rules {
+		rule, err := newRule(autoCastRule, config.Chromecasts)
+		if err != nil {
+			return nil, fmt.Errorf("Auto cast rule #%d: %v", i, err)
+		}
+		engine.rules = append(engine.rules, rule)
+	}
+	return &engine, nil
+}
+
+func newRule(autoCastRule models.AutoCastRule, chromecasts []models.Chromecast) (rule, error) {
+	streamer := strings.ToLower(strings.TrimSpace(autoCastRule.Streamer))
+	if streamer == "" {
+		return rule{}, errors.New("missing streamer")
+	}
+
+	var chromecast models.Chromecast
+	found := false
+	for _, configured := range chromecasts {
+		if strings.EqualFold(configured.Name, autoCastRule.Device) {
+			chromecast, found = configured, true
+		}
+	}
+	if !found {
+		return rule{}, errors.New("unknown device " + autoCastRule.Device)
+	}
+
+	if autoCastRule.Quality != "" {
+		if _, ok := streams.ParseQuality(autoCastRule.Quality); !ok {
+			return rule{}, errors.New("invalid quality " + autoCastRule.Quality)
+		}
+		chromecast.QualityMax = autoCastRule.Quality
+	}
+
+	hours, err := ParseHours(autoCastRule.ActiveHours)
+	if err != nil {
+		return rule{}, err
+	}
+	return rule{streamer, chromecast, hours, autoCastRule.OnlyIfIdle, streamer + " to " + chromecast.Name}, nil
+}
+
+// Start evaluates the rules in the background until ctx is cancelled, it does nothing without rules
+func (e *Engine) Start(ctx context.Context) {
+	if len(e.rules) == 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(autoCastPollInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := e.Evaluate(ctx); err != nil {
+				fmt.Println("Error evaluating auto cast rules: ", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Evaluate checks which streamers are live and casts those whose rules apply.
+// A rule that doesn't apply yet, outside its hours or while its device is busy, or whose cast failed, is tried again on the next evaluation.
+func (e *Engine) Evaluate(ctx context.Context) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	logins := []string{}
+	for _, rule := range e.rules {
+		logins = append(logins, rule.streamer)
+	}
+	live, err := e.live.FetchLiveLogins(ctx, logins)
+	if err != nil {
+		return err
+	}
+
+	now := e.clock.Now()
+	statuses := e.statuses.Statuses()
+	for i, rule := range e.rules {
+		if !live[rule.streamer] {
+			delete(e.fired, i)
+			delete(e.jobs, i)
+			continue
+		}
+		if e.fired[i] && e.jobFailed(i, rule) {
+			delete(e.fired, i)
+		}
+		if e.fired[i] || !rule.hours.Contains(now) {
+			continue
+		}
+
+		status := findStatus(statuses, rule.chromecast.Name)
+		if strings.EqualFold(status.Streamer, rule.streamer) {
+			e.fired[i] = true
+			continue
+		}
+		if rule.onlyIfIdle && status.AppRunning {
+			continue
+		}
+
+		e.fired[i] = true
+		if e.dryRun {
+			fmt.Println("Auto cast dry run, would cast", rule.description)
+			continue
+		}
+		fmt.Println("Auto casting", rule.description)
+		job, err := e.caster.StartCast(rule.streamer, rule.chromecast)
+		if err != nil {
+			fmt.Println("Error auto casting "+rule.description+": ", err)
+			delete(e.fired, i)
+			continue
+		}
+		e.jobs[i] = job.ID
+	}
+	return nil
+}
+
+// jobFailed reports whether the cast started for rule i has failed since, forgetting jobs that are playing
+func (e *Engine) jobFailed(i int, rule rule) bool {
+	id, ok := e.jobs[i]
+	if !ok {
+		return false
+	}
+	job, ok := e.caster.CastJob(id)
+	if !ok || job.State == cast.JobPlaying {
+		delete(e.jobs, i)
+		return false
+	}
+	if job.State != cast.JobFailed {
+		return false
+	}
+	fmt.Println("Error auto casting "+rule.description+", trying again: ", job.Error)
+	delete(e.jobs, i)
+	return true
+}
+
+func findStatus(statuses []cast.DeviceStatus, name string) cast.DeviceStatus {
+	for _, status := range statuses {
+		if status.Name == name {
+			return status
+		}
+	}
+	return cast.DeviceStatus{Name: name}
+}
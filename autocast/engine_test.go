@@ -0,0 +1,274 @@
+package autocast
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"twitch-caster/cast"
+	"twitch-caster/models"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// fakeLive reports the streamers in live as live, and records which logins it was asked about
+type fakeLive struct {
+	live   map[string]bool
+	err    error
+	logins []string
+}
+
+func (f *fakeLive) FetchLiveLogins(ctx context.Context, logins []string) (map[string]bool, error) {
+	f.logins = logins
+	return f.live, f.err
+}
+
+type fakeCaster struct {
+	casts []models.Chromecast
+	err   error
+	jobs  map[string]cast.Job
+}
+
+func (f *fakeCaster) StartCast(streamID string, chromecast models.Chromecast) (cast.Job, error) {
+	if f.err != nil {
+		return cast.Job{}, f.err
+	}
+	f.casts = append(f.casts, chromecast)
+	job := cast.Job{ID: strconv.Itoa(len(f.casts)), Streamer: streamID, State: cast.JobResolving}
+	f.jobs[job.ID] = job
+	return job, nil
+}
+
+func (f *fakeCaster) CastJob(id string) (cast.Job, bool) {
+	job, ok := f.jobs[id]
+	return job, ok
+}
+
+// finish moves the last job to state
+func (f *fakeCaster) finish(state cast.JobState) {
+	job := f.jobs[strconv.Itoa(len(f.casts))]
+	job.State = state
+	if state == cast.JobFailed {
+		job.Error = "No playable streams found"
+	}
+	f.jobs[job.ID] = job
+}
+
+// fakeStatuses lets tests change the device statuses between evaluations
+type fakeStatuses []cast.DeviceStatus
+
+func (f *fakeStatuses) Statuses() []cast.DeviceStatus {
+	return *f
+}
+
+// testEngine has fakes for everything an Engine talks to, starting at 20:00 with nobody live
+type testEngine struct {
+	*Engine
+	clock    *fakeClock
+	live     *fakeLive
+	caster   *fakeCaster
+	statuses fakeStatuses
+}
+
+func newTestEngine(t *testing.T, autoCast models.AutoCast) *testEngine {
+	config := models.Configuration{
+		Chromecasts: []models.Chromecast{
+			{Name: "Living Room", IPAddress: "192.168.1.1", QualityMax: "best"},
+			{Name: "Kitchen", QualityMax: "720p"},
+		},
+		AutoCast: autoCast,
+	}
+	test := &testEngine{
+		clock:    &fakeClock{at(0, 20, 0)},
+		live:     &fakeLive{live: map[string]bool{}},
+		caster:   &fakeCaster{jobs: make(map[string]cast.Job)},
+		statuses: fakeStatuses{{Name: "Living Room", Online: true}, {Name: "Kitchen", Online: true}},
+	}
+	engine, err := NewEngine(config, test.live, test.caster, &test.statuses, test.clock)
+	if err != nil {
+		t.Fatal(err)
+	}
+	test.Engine = engine
+	return test
+}
+
+// evaluate evaluates the rules and checks how many casts have been started in total
+func (e *testEngine) evaluate(t *testing.T, wantCasts int) {
+	t.Helper()
+	if err := e.Evaluate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.caster.casts) != wantCasts {
+		t.Fatalf("%d casts at %s, want %d", len(e.caster.casts), e.clock.now.Format("15:04"), wantCasts)
+	}
+}
+
+func singleRule(rule models.AutoCastRule) models.AutoCast {
+	return models.AutoCast{Rules: []models.AutoCastRule{rule}}
+}
+
+func TestNewEngineInvalidRules(t *testing.T) {
+	tests := []struct {
+		rule  models.AutoCastRule
+		error string
+	}{
+		{models.AutoCastRule{Streamer: " ", Device: "Kitchen"}, "missing streamer"},
+		{models.AutoCastRule{Streamer: "someone", Device: "Bedroom"}, "unknown device Bedroom"},
+		{models.AutoCastRule{Streamer: "someone", Device: "Kitchen", Quality: "4k"}, "invalid quality 4k"},
+		{models.AutoCastRule{Streamer: "someone", Device: "Kitchen", ActiveHours: "evenings"}, "Invalid active hours"},
+	}
+	for _, test := range tests {
+		config := models.Configuration{Chromecasts: []models.Chromecast{{Name: "Kitchen"}}, AutoCast: singleRule(test.rule)}
+		_, err := NewEngine(config, &fakeLive{}, &fakeCaster{}, &fakeStatuses{}, &fakeClock{})
+		if err == nil || !strings.HasPrefix(err.Error(), "Auto cast rule #0: ") || !strings.Contains(err.Error(), test.error) {
+			t.Errorf("rule %+v: error = %v, want %q", test.rule, err, test.error)
+		}
+	}
+}
+
+func TestEvaluateCastsOncePerBroadcast(t *testing.T) {
+	engine := newTestEngine(t, singleRule(models.AutoCastRule{Streamer: " SomeStreamer ", Device: "kitchen", Quality: "480p"}))
+
+	engine.evaluate(t, 0)
+	if !reflect.DeepEqual(engine.live.logins, []string{"somestreamer"}) {
+		t.Errorf("asked about %v", engine.live.logins)
+	}
+
+	engine.live.live["somestreamer"] = true
+	engine.evaluate(t, 1)
+	// The rule's quality replaces the device's
+	if chromecast := engine.caster.casts[0]; chromecast.Name != "Kitchen" || chromecast.QualityMax != "480p" {
+		t.Errorf("cast to %+v", chromecast)
+	}
+
+	// Still live, even once the cast has been stopped
+	engine.caster.finish(cast.JobPlaying)
+	engine.evaluate(t, 1)
+	engine.evaluate(t, 1)
+
+	// Going offline resets the rule, so the next broadcast is cast
+	engine.live.live["somestreamer"] = false
+	engine.evaluate(t, 1)
+	engine.live.live["somestreamer"] = true
+	engine.evaluate(t, 2)
+	engine.evaluate(t, 2)
+}
+
+func TestEvaluateActiveHoursAcrossMidnight(t *testing.T) {
+	engine := newTestEngine(t, singleRule(models.AutoCastRule{Streamer: "someone", Device: "Kitchen", ActiveHours: "22:00-02:00"}))
+	engine.live.live["someone"] = true
+
+	// Going live before the hours start casts once they do
+	engine.clock.now = at(0, 21, 59)
+	engine.evaluate(t, 0)
+	engine.clock.now = at(0, 22, 0)
+	engine.evaluate(t, 1)
+
+	// Past midnight it is the same broadcast
+	engine.clock.now = at(1, 0, 30)
+	engine.evaluate(t, 1)
+
+	// A new broadcast after midnight is still within the hours
+	engine.live.live["someone"] = false
+	engine.evaluate(t, 1)
+	engine.live.live["someone"] = true
+	engine.clock.now = at(1, 1, 59)
+	engine.evaluate(t, 2)
+
+	// But not once they are over
+	engine.live.live["someone"] = false
+	engine.evaluate(t, 2)
+	engine.live.live["someone"] = true
+	engine.clock.now = at(1, 2, 0)
+	engine.evaluate(t, 2)
+	engine.clock.now = at(1, 21, 0)
+	engine.evaluate(t, 2)
+}
+
+func TestEvaluateOnlyIfIdle(t *testing.T) {
+	engine := newTestEngine(t, models.AutoCast{Rules: []models.AutoCastRule{
+		{Streamer: "someone", Device: "Living Room", OnlyIfIdle: true},
+		{Streamer: "someone", Device: "Kitchen"},
+	}})
+	engine.statuses = fakeStatuses{
+		{Name: "Living Room", Online: true, AppRunning: true, AppName: "YouTube"},
+		{Name: "Kitchen", Online: true, AppRunning: true, AppName: "YouTube"},
+	}
+	engine.live.live["someone"] = true
+
+	// Only the rule that doesn't wait for its device to be idle casts
+	engine.evaluate(t, 1)
+	if engine.caster.casts[0].Name != "Kitchen" {
+		t.Errorf("cast to %s", engine.caster.casts[0].Name)
+	}
+
+	// The other rule casts once its device is free
+	engine.evaluate(t, 1)
+	engine.statuses[0] = cast.DeviceStatus{Name: "Living Room", Online: true}
+	engine.evaluate(t, 2)
+	if engine.caster.casts[1].Name != "Living Room" {
+		t.Errorf("cast to %s", engine.caster.casts[1].Name)
+	}
+}
+
+func TestEvaluateAlreadyPlaying(t *testing.T) {
+	engine := newTestEngine(t, singleRule(models.AutoCastRule{Streamer: "someone", Device: "Kitchen"}))
+	engine.statuses[1] = cast.DeviceStatus{Name: "Kitchen", Online: true, AppRunning: true, Streamer: "Someone"}
+	engine.live.live["someone"] = true
+
+	// Someone cast it by hand, switching to something else afterwards isn't undone
+	engine.evaluate(t, 0)
+	engine.statuses[1] = cast.DeviceStatus{Name: "Kitchen", Online: true}
+	engine.evaluate(t, 0)
+}
+
+func TestEvaluateDryRun(t *testing.T) {
+	autoCast := singleRule(models.AutoCastRule{Streamer: "someone", Device: "Kitchen"})
+	autoCast.DryRun = true
+	engine := newTestEngine(t, autoCast)
+	engine.live.live["someone"] = true
+
+	engine.evaluate(t, 0)
+	if !engine.fired[0] {
+		t.Error("a dry run didn't mark the rule as fired")
+	}
+	engine.evaluate(t, 0)
+}
+
+func TestEvaluateRetriesFailedCasts(t *testing.T) {
+	engine := newTestEngine(t, singleRule(models.AutoCastRule{Streamer: "someone", Device: "Kitchen"}))
+	engine.live.live["someone"] = true
+
+	// The device hasn't been found yet
+	engine.caster.err = errors.New("Chromecast device Kitchen has not been found on the network")
+	engine.evaluate(t, 0)
+	engine.caster.err = nil
+	engine.evaluate(t, 1)
+
+	// The cast started but resolving the stream failed later on
+	engine.caster.finish(cast.JobFailed)
+	engine.evaluate(t, 2)
+
+	// Once a cast plays it isn't tried again
+	engine.caster.finish(cast.JobPlaying)
+	engine.evaluate(t, 2)
+	engine.evaluate(t, 2)
+}
+
+func TestEvaluateLiveCheckFails(t *testing.T) {
+	engine := newTestEngine(t, singleRule(models.AutoCastRule{Streamer: "someone", Device: "Kitchen"}))
+	engine.live.err = errors.New("Twitch is down")
+	if err := engine.Evaluate(context.Background()); err != engine.live.err {
+		t.Errorf("error = %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package autocast
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Hours is a daily time range such as 18:00-23:30, a range that ends before it starts runs past midnight
+// and one that ends when it starts lasts all day
+type Hours struct {
+	start time.Duration
+	end   time.Duration
+	all   bool
+}
+
+// ParseHours parses a range like 18:00-23:30, an empty range is active all day
+func ParseHours(hours string) (Hours, error) {
+	hours = strings.TrimSpace(hours)
+	if hours == "" {
+		return Hours{all: true}, nil
+	}
+
+	parts := strings.Split(hours, "-")
+	if len(parts) != 2 {
+		return Hours{}, errors.New("Invalid active hours " + hours + ", expected a range like 18:00-23:30")
+	}
+	start, err := time.Parse("15:04", strings.TrimSpace(parts[0]))
+	if err != nil {
+		return Hours{}, errors.New("Invalid start time in active hours " + hours)
+	}
+	end, err := time.Parse("15:04", strings.TrimSpace(parts[1]))
+	if err != nil {
+		return Hours{}, errors.New("Invalid end time in active hours " + hours)
+	}
+	return Hours{start: sinceMidnight(start), end: sinceMidnight(end), all: start.Equal(end)}, nil
+}
+
+// Contains reports whether the time of day of t falls within the range, the end is exclusive
+func (h Hours) Contains(t time.Time) bool {
+	if h.all {
+		return true
+	}
+	now := sinceMidnight(t)
+	if h.start <= h.end {
+		return now >= h.start && now < h.end
+	}
+	return now >= h.start || now < h.end
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+}
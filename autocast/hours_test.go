@@ -0,0 +1,55 @@
+package autocast
+
+import (
+	"testing"
+	"time"
+)
+
+// at returns a time on 1 March 2021 at hour:minute, or the next day when day is 1
+func at(day int, hour int, minute int) time.Time {
+	return time.Date(2021, time.March, 1+day, hour, minute, 0, 0, time.Local)
+}
+
+func TestParseHoursInvalid(t *testing.T) {
+	for _, hours := range []string{"18:00", "18:00-", "-23:30", "18:00-23:30-01:00", "6pm-11pm", "25:00-23:00", "18:00-23:60"} {
+		if _, err := ParseHours(hours); err == nil {
+			t.Errorf("ParseHours(%q) succeeded", hours)
+		}
+	}
+}
+
+func TestHoursContains(t *testing.T) {
+	tests := []struct {
+		hours string
+		time  time.Time
+		want  bool
+	}{
+		{"", at(0, 3, 0), true},
+		{"18:00-18:00", at(0, 3, 0), true},
+		{"18:00-23:30", at(0, 17, 59), false},
+		{"18:00-23:30", at(0, 18, 0), true},
+		{" 18:00 - 23:30 ", at(0, 20, 0), true},
+		{"18:00-23:30", at(0, 23, 29), true},
+		{"18:00-23:30", at(0, 23, 30), false},
+		// Ranges that end before they start run past midnight
+		{"22:00-02:00", at(0, 21, 59), false},
+		{"22:00-02:00", at(0, 22, 0), true},
+		{"22:00-02:00", at(0, 23, 59), true},
+		{"22:00-02:00", at(1, 0, 0), true},
+		{"22:00-02:00", at(1, 1, 59), true},
+		{"22:00-02:00", at(1, 2, 0), false},
+		{"22:00-02:00", at(1, 12, 0), false},
+		{"00:00-06:00", at(1, 0, 0), true},
+		{"20:00-00:00", at(0, 23, 59), true},
+		{"20:00-00:00", at(1, 0, 0), false},
+	}
+	for _, test := range tests {
+		hours, err := ParseHours(test.hours)
+		if err != nil {
+			t.Fatalf("ParseHours(%q): %v", test.hours, err)
+		}
+		if got := hours.Contains(test.time); got != test.want {
+			t.Errorf("%q contains %s = %v, want %v", test.hours, test.time.Format("15:04"), got, test.want)
+		}
+	}
+}
@@ -5,5 +5,5 @@ go build
 set GOOS=
 set GOARCH=
 set GOARM=
-scp -r twitch-caster configuration.json static pi@raspberrypi:
+scp twitch-caster configuration.json pi@raspberrypi:
 del twitch-caster
\ No newline at end of file
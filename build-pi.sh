@@ -1,3 +1,3 @@
 GOOS=linux GOARCH=arm GOARM=5 go build
-scp -r twitch-caster configuration.json static pi@raspberrypi:
+scp twitch-caster configuration.json pi@raspberrypi:
 rm twitch-caster
\ No newline at end of file
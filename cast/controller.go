@@ -0,0 +1,200 @@
+package cast
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vishen/go-chromecast/application"
+	"github.com/vishen/go-chromecast/cmd"
+)
+
+const chromecastPort = 8009
+
+// Devices are checked for a listening port this quickly before a session is started with them
+const dialTimeout = 3 * time.Second
+
+// go-chromecast retries a stale session five times two seconds apart by default, holding up every command to the
+// device while it does. A single attempt is enough since a failed session is replaced with a new one.
+const connectionRetries = 1
+
+// Controller keeps one go-chromecast application per device, shared by status polling and every command,
+// so active casts can be controlled after loading without connecting again
+type Controller struct {
+	mutex   sync.Mutex
+	devices map[string]*device
+}
+
+type device struct {
+	mutex    sync.Mutex
+	app      *application.Application
+	mediaURL string
+	streamer string
+}
+
+// NewController creates a new Controller object
+func NewController() *Controller {
+	controller := Controller{}
+	controller.devices = make(map[string]*device)
+	return &controller
+}
+
+// Load takes a URL and IPAddress of a Chromecast device to play video on, streamer is reported back by Status
+func (c *Controller) Load(url string, ipAddress string, streamer string) error {
+	return c.withDevice(ipAddress, func(device *device) error {
+		if err := device.app.Load(url, "", false, true); err != nil {
+			fmt.Printf("unable to load media: %v\n", err)
+			return err
+		}
+		device.mediaURL = url
+		device.streamer = streamer
+		return nil
+	})
+}
+
+// Status queries the receiver and media status of a Chromecast device
+func (c *Controller) Status(ipAddress string) (DeviceStatus, error) {
+	status := DeviceStatus{IPAddress: ipAddress}
+	err := c.withDevice(ipAddress, func(device *device) error {
+		app, media, volume := device.app.Status()
+		status.Online = true
+		if volume != nil {
+			status.Volume = volume.Level
+			status.Muted = volume.Muted
+		}
+		if app == nil || app.IsIdleScreen {
+			return nil
+		}
+
+		status.AppRunning = true
+		status.AppName = app.DisplayName
+		if media != nil {
+			status.MediaURL = media.Media.ContentId
+			status.PlayerState = media.PlayerState
+			if status.MediaURL == device.mediaURL {
+				status.Streamer = device.streamer
+			}
+		}
+		return nil
+	})
+	return status, err
+}
+
+// Pause pauses the media playing on a Chromecast device
+func (c *Controller) Pause(ipAddress string) error {
+	return c.withApplication(ipAddress, func(app *application.Application) error {
+		return app.Pause()
+	})
+}
+
+// Resume resumes paused media on a Chromecast device
+func (c *Controller) Resume(ipAddress string) error {
+	return c.withApplication(ipAddress, func(app *application.Application) error {
+		return app.Unpause()
+	})
+}
+
+// Stop stops playback and closes the media receiver on a Chromecast device, the session with the device is kept
+func (c *Controller) Stop(ipAddress string) error {
+	return c.withDevice(ipAddress, func(device *device) error {
+		if err := device.app.Stop(); err != nil {
+			return err
+		}
+		device.mediaURL = ""
+		device.streamer = ""
+		return nil
+	})
+}
+
+// Seek moves the playback position by a relative number of seconds
+func (c *Controller) Seek(ipAddress string, seconds int) error {
+	return c.withApplication(ipAddress, func(app *application.Application) error {
+		return app.Seek(seconds)
+	})
+}
+
+// SetVolume sets the volume of a Chromecast device, level is between 0 and 1
+func (c *Controller) SetVolume(ipAddress string, level float32) error {
+	if level < 0 || level > 1 {
+		return errors.New("Volume must be between 0 and 1")
+	}
+	return c.withApplication(ipAddress, func(app *application.Application) error {
+		return app.SetVolume(level)
+	})
+}
+
+// SetMuted mutes or unmutes a Chromecast device
+func (c *Controller) SetMuted(ipAddress string, muted bool) error {
+	return c.withApplication(ipAddress, func(app *application.Application) error {
+		return app.SetMuted(muted)
+	})
+}
+
+func (c *Controller) withApplication(ipAddress string, action func(app *application.Application) error) error {
+	return c.withDevice(ipAddress, func(device *device) error {
+		return action(device.app)
+	})
+}
+
+// withDevice runs action with the device locked and connected, reconnecting once if the saved session has gone stale.
+// The goroutines go-chromecast started for a stale session can't be stopped, so sessions are only replaced when they fail.
+func (c *Controller) withDevice(ipAddress string, action func(device *device) error) error {
+	device := c.device(ipAddress)
+	device.mutex.Lock()
+	defer device.mutex.Unlock()
+
+	if device.app != nil {
+		if err := device.app.Update(); err == nil {
+			return action(device)
+		}
+		fmt.Println("Lost connection to Chromecast, reconnecting: ", ipAddress)
+		device.app.Close()
+		device.app = nil
+	}
+
+	app, err := startApplication(ipAddress)
+	if err != nil {
+		return err
+	}
+	device.app = app
+	return action(device)
+}
+
+func (c *Controller) device(ipAddress string) *device {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	d, ok := c.devices[ipAddress]
+	if !ok {
+		d = &device{}
+		c.devices[ipAddress] = d
+	}
+	return d
+}
+
+// startApplication connects to the device at address, which may include a port
+func startApplication(address string) (*application.Application, error) {
+	ipAddress, port := SplitAddress(address)
+
+	// Every Application starts goroutines that live as long as the process, so don't make one for a device that isn't there
+	probe, err := net.DialTimeout("tcp", net.JoinHostPort(ipAddress, strconv.Itoa(port)), dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	probe.Close()
+
+	app := application.NewApplication(application.WithConnectionRetries(connectionRetries))
+	entry := cmd.CachedDNSEntry{
+		Addr: ipAddress,
+		Port: port,
+	}
+
+	if err := app.Start(entry); err != nil {
+		fmt.Println("Unable to start app", err)
+		return nil, err
+	}
+	return app, nil
+}
@@ -0,0 +1,231 @@
+package cast
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	pb "github.com/vishen/go-chromecast/cast/proto"
+)
+
+// closedAddress returns an address nothing is listening on
+func closedAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	return address
+}
+
+func TestStatusUnreachableDeviceDoesNotLeak(t *testing.T) {
+	controller := NewController()
+	address := closedAddress(t)
+
+	if _, err := controller.Status(address); err == nil {
+		t.Fatal("expected an error for an unreachable device")
+	}
+	before := runtime.NumGoroutine()
+	for i := 0; i < 20; i++ {
+		if _, err := controller.Status(address); err == nil {
+			t.Fatal("expected an error for an unreachable device")
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("goroutines grew from %d to %d polling an unreachable device", before, after)
+	}
+}
+
+// fakeChromecast speaks enough of the Cast protocol over TLS to answer status requests, and records what it is sent
+type fakeChromecast struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	conns    []net.Conn
+	sessions int
+	received []string
+}
+
+func newFakeChromecast(t *testing.T) *fakeChromecast {
+	if raceEnabled {
+		t.Skip("go-chromecast isn't safe to run under the race detector")
+	}
+
+	// Borrow the certificate httptest makes for its TLS servers
+	server := httptest.NewUnstartedServer(nil)
+	server.StartTLS()
+	certificates := server.TLS.Certificates
+	server.Close()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	device := &fakeChromecast{listener: listener}
+	t.Cleanup(func() {
+		listener.Close()
+		device.dropConnections()
+	})
+	go device.accept()
+	return device
+}
+
+func (f *fakeChromecast) address() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeChromecast) accept() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		f.mutex.Lock()
+		f.conns = append(f.conns, conn)
+		f.mutex.Unlock()
+		go f.serve(conn.(*tls.Conn))
+	}
+}
+
+func (f *fakeChromecast) serve(conn *tls.Conn) {
+	// Connections that are closed straight away are only checking the device is there
+	if err := conn.Handshake(); err != nil {
+		return
+	}
+	f.mutex.Lock()
+	f.sessions++
+	f.mutex.Unlock()
+
+	for {
+		var length uint32
+		if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+			return
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(conn, data); err != nil {
+			return
+		}
+		message := &pb.CastMessage{}
+		if err := proto.Unmarshal(data, message); err != nil {
+			return
+		}
+		var payload struct {
+			Type      string `json:"type"`
+			RequestID int    `json:"requestId"`
+		}
+		json.Unmarshal([]byte(message.GetPayloadUtf8()), &payload)
+		f.mutex.Lock()
+		f.received = append(f.received, payload.Type)
+		f.mutex.Unlock()
+
+		// The device is idle, so only the receiver is ever asked for its status
+		if payload.Type == "GET_STATUS" && message.GetNamespace() == "urn:x-cast:com.google.cast.receiver" {
+			status := `{"type":"RECEIVER_STATUS","requestId":` + strconv.Itoa(payload.RequestID) + `,"status":{"applications":[],"volume":{"level":0.5,"muted":false}}}`
+			f.send(conn, message, status)
+		}
+	}
+}
+
+// send replies to request with payload
+func (f *fakeChromecast) send(conn net.Conn, request *pb.CastMessage, payload string) {
+	reply := &pb.CastMessage{
+		ProtocolVersion: pb.CastMessage_CASTV2_1_0.Enum(),
+		SourceId:        request.DestinationId,
+		DestinationId:   request.SourceId,
+		Namespace:       request.Namespace,
+		PayloadType:     pb.CastMessage_STRING.Enum(),
+		PayloadUtf8:     &payload,
+	}
+	data, err := proto.Marshal(reply)
+	if err != nil {
+		return
+	}
+	binary.Write(conn, binary.BigEndian, uint32(len(data)))
+	conn.Write(data)
+}
+
+// dropConnections closes every connection, as a device does when it restarts
+func (f *fakeChromecast) dropConnections() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, conn := range f.conns {
+		conn.Close()
+	}
+	f.conns = nil
+}
+
+// connections returns how many TLS sessions have been started with the device
+func (f *fakeChromecast) connections() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.sessions
+}
+
+func (f *fakeChromecast) receivedType(messageType string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for _, received := range f.received {
+		if received == messageType {
+			return true
+		}
+	}
+	return false
+}
+
+func TestStatusReusesSession(t *testing.T) {
+	device := newFakeChromecast(t)
+	controller := NewController()
+
+	for i := 0; i < 3; i++ {
+		status, err := controller.Status(device.address())
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := DeviceStatus{IPAddress: device.address(), Online: true, Volume: 0.5}
+		if !reflect.DeepEqual(status, want) {
+			t.Errorf("status = %+v, want %+v", status, want)
+		}
+	}
+
+	// Stopping a cast keeps the session for the next status poll
+	if err := controller.Stop(device.address()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := controller.Status(device.address()); err != nil {
+		t.Fatal(err)
+	}
+	if !device.receivedType("STOP") {
+		t.Error("the device wasn't told to stop")
+	}
+	if connections := device.connections(); connections != 1 {
+		t.Errorf("%d connections to the device, want 1", connections)
+	}
+}
+
+func TestStatusReconnectsLostSession(t *testing.T) {
+	device := newFakeChromecast(t)
+	controller := NewController()
+	if _, err := controller.Status(device.address()); err != nil {
+		t.Fatal(err)
+	}
+
+	device.dropConnections()
+	status, err := controller.Status(device.address())
+	if err != nil || !status.Online {
+		t.Fatalf("status = %+v, error %v", status, err)
+	}
+	if connections := device.connections(); connections != 2 {
+		t.Errorf("%d connections to the device, want 2", connections)
+	}
+}
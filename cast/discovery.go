@@ -0,0 +1,201 @@
+package cast
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/vishen/go-chromecast/dns"
+
+	"twitch-caster/models"
+)
+
+// How long each browse listens for answers, a var so tests can shorten it
+var browseDuration = 5 * time.Second
+
+const browseInterval = time.Minute
+
+// Devices that haven't answered a few browses in a row are dropped from the registry
+const deviceExpiry = 3 * browseInterval
+
+// Device is a Chromecast that has been found on the network
+type Device struct {
+	Name      string    `json:"name"`
+	UUID      string    `json:"uuid"`
+	IPAddress string    `json:"ipAddress"`
+	Port      int       `json:"port"`
+	LastSeen  time.Time `json:"lastSeen"`
+}
+
+// Browser finds cast devices on the network
+type Browser interface {
+	Browse(ctx context.Context) (<-chan dns.CastEntry, error)
+}
+
+// MDNSBrowser browses for _googlecast._tcp services over mDNS
+type MDNSBrowser struct{}
+
+// Browse sends every cast device that answers on the returned channel until ctx is done
+func (MDNSBrowser) Browse(ctx context.Context) (<-chan dns.CastEntry, error) {
+	return dns.DiscoverCastDNSEntries(ctx, nil)
+}
+
+// Registry keeps a live list of discovered Chromecast devices keyed by UUID
+type Registry struct {
+	mutex   sync.RWMutex
+	browser Browser
+	devices map[string]Device
+}
+
+// NewRegistry creates a new Registry object that discovers devices using browser
+func NewRegistry(browser Browser) *Registry {
+	registry := Registry{}
+	registry.browser = browser
+	registry.devices = make(map[string]Device)
+	return &registry
+}
+
+// Start browses the network in the background until ctx is cancelled
+func (r *Registry) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(browseInterval)
+		defer ticker.Stop()
+
+		for {
+			if err := r.Refresh(ctx); err != nil {
+				fmt.Println("Error discovering Chromecasts: ", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Refresh runs a single browse and updates the registry with the devices that answered
+func (r *Registry) Refresh(ctx context.Context) error {
+	browseContext, cancel := context.WithTimeout(ctx, browseDuration)
+	defer cancel()
+
+	entries, err := r.browser.Browse(browseContext)
+	if err != nil {
+		return err
+	}
+
+	for entry := range entries {
+		r.add(entry)
+	}
+	r.expire()
+	return nil
+}
+
+// Lookup finds a discovered device by its friendly name.
+// When devices share a name the one with the lowest UUID is found, so the same one is cast to every time.
+func (r *Registry) Lookup(name string) (Device, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var found Device
+	ok := false
+	for _, device := range r.devices {
+		if device.Name == name && (!ok || device.UUID < found.UUID) {
+			found = device
+			ok = true
+		}
+	}
+	return found, ok
+}
+
+// Devices returns every discovered device sorted by name
+func (r *Registry) Devices() []Device {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	devices := make([]Device, 0, len(r.devices))
+	for _, device := range r.devices {
+		devices = append(devices, device)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].Name < devices[j].Name
+	})
+	return devices
+}
+
+// Address returns the configured address of a Chromecast, falling back to the discovered one.
+// Devices on a port other than the default, such as cast groups, have the port appended like 192.168.1.5:32187.
+func (r *Registry) Address(chromecast models.Chromecast) string {
+	if chromecast.IPAddress != "" {
+		return chromecast.IPAddress
+	}
+
+	device, ok := r.Lookup(chromecast.Name)
+	if !ok {
+		return ""
+	}
+	return JoinAddress(device.IPAddress, device.Port)
+}
+
+// JoinAddress returns the address of a device, leaving the port out when it is the default
+func JoinAddress(ipAddress string, port int) string {
+	if port == 0 || port == chromecastPort {
+		return ipAddress
+	}
+	return net.JoinHostPort(ipAddress, strconv.Itoa(port))
+}
+
+// SplitAddress returns the IP address and port of an address made by JoinAddress
+func SplitAddress(address string) (string, int) {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return address, chromecastPort
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return host, chromecastPort
+	}
+	return host, port
+}
+
+func (r *Registry) add(entry dns.CastEntry) {
+	if entry.UUID == "" || entry.AddrV4 == nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.devices[entry.UUID]; !ok {
+		fmt.Println("Discovered Chromecast: ", entry.DeviceName, entry.GetAddr())
+		for _, device := range r.devices {
+			if device.Name == entry.DeviceName {
+				fmt.Println("Chromecasts "+device.UUID+" and "+entry.UUID+" are both called "+entry.DeviceName+",",
+					"the one with the lowest UUID is used unless ipAddress is set in configuration.json")
+			}
+		}
+	}
+	r.devices[entry.UUID] = Device{
+		Name:      entry.DeviceName,
+		UUID:      entry.UUID,
+		IPAddress: entry.GetAddr(),
+		Port:      entry.Port,
+		LastSeen:  time.Now(),
+	}
+}
+
+func (r *Registry) expire() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for uuid, device := range r.devices {
+		if time.Since(device.LastSeen) > deviceExpiry {
+			delete(r.devices, uuid)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package cast
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/grandcat/zeroconf"
+	"github.com/vishen/go-chromecast/dns"
+
+	"twitch-caster/models"
+)
+
+// fakeBrowser answers every browse with the same entries, as an mDNS responder would
+type fakeBrowser struct {
+	entries []dns.CastEntry
+}
+
+func (f *fakeBrowser) Browse(ctx context.Context) (<-chan dns.CastEntry, error) {
+	entries := make(chan dns.CastEntry, len(f.entries))
+	for _, entry := range f.entries {
+		entries <- entry
+	}
+	close(entries)
+	return entries, nil
+}
+
+func TestRegistryRefresh(t *testing.T) {
+	browser := &fakeBrowser{entries: []dns.CastEntry{
+		{UUID: "1", DeviceName: "Living Room", AddrV4: net.ParseIP("192.168.1.10"), Port: 8009},
+		{UUID: "2", DeviceName: "Whole House", AddrV4: net.ParseIP("192.168.1.11"), Port: 32187},
+		{UUID: "", DeviceName: "No UUID", AddrV4: net.ParseIP("192.168.1.12"), Port: 8009},
+		{UUID: "4", DeviceName: "No Address", Port: 8009},
+	}}
+	registry := NewRegistry(browser)
+	if err := registry.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	devices := registry.Devices()
+	if len(devices) != 2 || devices[0].Name != "Living Room" || devices[1].Name != "Whole House" {
+		t.Fatalf("Devices() = %+v, want Living Room and Whole House", devices)
+	}
+
+	tests := []struct {
+		chromecast models.Chromecast
+		want       string
+	}{
+		{models.Chromecast{Name: "Living Room"}, "192.168.1.10"},
+		{models.Chromecast{Name: "Whole House"}, "192.168.1.11:32187"},
+		{models.Chromecast{Name: "Kitchen"}, ""},
+		{models.Chromecast{Name: "Living Room", IPAddress: "10.0.0.5"}, "10.0.0.5"},
+	}
+	for _, test := range tests {
+		if got := registry.Address(test.chromecast); got != test.want {
+			t.Errorf("Address(%+v) = %q, want %q", test.chromecast, got, test.want)
+		}
+	}
+}
+
+func TestRegistryRefreshUpdatesMovedDevice(t *testing.T) {
+	browser := &fakeBrowser{entries: []dns.CastEntry{
+		{UUID: "1", DeviceName: "Living Room", AddrV4: net.ParseIP("192.168.1.10"), Port: 8009},
+	}}
+	registry := NewRegistry(browser)
+	registry.Refresh(context.Background())
+
+	browser.entries[0].AddrV4 = net.ParseIP("192.168.1.20")
+	registry.Refresh(context.Background())
+
+	if got := registry.Address(models.Chromecast{Name: "Living Room"}); got != "192.168.1.20" {
+		t.Errorf("Address() = %q after the device moved, want 192.168.1.20", got)
+	}
+}
+
+func TestRegistryLookupSharedName(t *testing.T) {
+	browser := &fakeBrowser{entries: []dns.CastEntry{
+		{UUID: "b", DeviceName: "Living Room", AddrV4: net.ParseIP("192.168.1.20"), Port: 8009},
+		{UUID: "a", DeviceName: "Living Room", AddrV4: net.ParseIP("192.168.1.21"), Port: 8009},
+		{UUID: "c", DeviceName: "Living Room", AddrV4: net.ParseIP("192.168.1.22"), Port: 8009},
+	}}
+	registry := NewRegistry(browser)
+	registry.Refresh(context.Background())
+
+	// Map order changes from one lookup to the next, the device found mustn't
+	for i := 0; i < 20; i++ {
+		if device, ok := registry.Lookup("Living Room"); !ok || device.UUID != "a" {
+			t.Fatalf("Lookup() = %+v, %v, want the device with UUID a", device, ok)
+		}
+	}
+}
+
+func TestSplitAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		host    string
+		port    int
+	}{
+		{"192.168.1.10", "192.168.1.10", 8009},
+		{"192.168.1.11:32187", "192.168.1.11", 32187},
+		{JoinAddress("192.168.1.12", 8009), "192.168.1.12", 8009},
+		{JoinAddress("192.168.1.13", 0), "192.168.1.13", 8009},
+	}
+	for _, test := range tests {
+		host, port := SplitAddress(test.address)
+		if host != test.host || port != test.port {
+			t.Errorf("SplitAddress(%q) = %q, %d, want %q, %d", test.address, host, port, test.host, test.port)
+		}
+	}
+}
+
+// TestMDNSBrowser browses for a device announced by an in-process mDNS responder, as a Chromecast would announce itself
+func TestMDNSBrowser(t *testing.T) {
+	duration := browseDuration
+	browseDuration = time.Second
+	t.Cleanup(func() { browseDuration = duration })
+
+	text := []string{"id=0123456789abcdef", "md=Chromecast", "fn=Test Room"}
+	server, err := zeroconf.RegisterProxy("Chromecast-0123456789abcdef", "_googlecast._tcp", "local.", 32187, "chromecast-test", []string{"192.0.2.10", "2001:db8::10"}, text, nil)
+	if err != nil {
+		t.Skip("Unable to run an mDNS responder: ", err)
+	}
+	defer server.Shutdown()
+	// Casting needs an IPv4 address, so devices without one are left out
+	text = []string{"id=fedcba9876543210", "md=Chromecast", "fn=IPv6 Room"}
+	ipv6Server, err := zeroconf.RegisterProxy("Chromecast-fedcba9876543210", "_googlecast._tcp", "local.", 8009, "chromecast-ipv6", []string{"2001:db8::11"}, text, nil)
+	if err != nil {
+		t.Skip("Unable to run an mDNS responder: ", err)
+	}
+	defer ipv6Server.Shutdown()
+
+	registry := NewRegistry(MDNSBrowser{})
+	if err := registry.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	device, ok := registry.Lookup("Test Room")
+	if !ok {
+		t.Fatalf("the device wasn't found, found %+v", registry.Devices())
+	}
+	if device.UUID != "0123456789abcdef" || device.IPAddress != "192.0.2.10" || device.Port != 32187 {
+		t.Errorf("device = %+v", device)
+	}
+	if address := registry.Address(models.Chromecast{Name: "Test Room"}); address != "192.0.2.10:32187" {
+		t.Errorf("Address() = %q", address)
+	}
+	if device, ok := registry.Lookup("IPv6 Room"); ok {
+		t.Errorf("found %+v without an IPv4 address", device)
+	}
+}
@@ -0,0 +1,115 @@
+package cast
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// JobState is the stage a cast job has reached
+type JobState string
+
+// Cast jobs move from resolving to loading to playing, or stop at failed
+const (
+	JobResolving JobState = "resolving"
+	JobLoading   JobState = "loading"
+	JobPlaying   JobState = "playing"
+	JobFailed    JobState = "failed"
+)
+
+// Finished jobs are kept around long enough for the page to poll their result
+const jobRetention = 10 * time.Minute
+
+// Job is a single request to cast a stream to a Chromecast
+type Job struct {
+	ID        string    `json:"id"`
+	Streamer  string    `json:"streamer"`
+	IPAddress string    `json:"ipAddress"`
+	State     JobState  `json:"state"`
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// JobTracker keeps the state of recent cast jobs
+type JobTracker struct {
+	mutex sync.RWMutex
+	jobs  map[string]Job
+}
+
+// NewJobTracker creates a new JobTracker object
+func NewJobTracker() *JobTracker {
+	tracker := JobTracker{}
+	tracker.jobs = make(map[string]Job)
+	return &tracker
+}
+
+// Create starts tracking a new job in the resolving state
+func (j *JobTracker) Create(streamer string, ipAddress string) Job {
+	now := time.Now()
+	job := Job{
+		ID:        newJobID(),
+		Streamer:  streamer,
+		IPAddress: ipAddress,
+		State:     JobResolving,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	j.prune()
+	j.jobs[job.ID] = job
+	return job
+}
+
+// SetState moves a job to a new state
+func (j *JobTracker) SetState(id string, state JobState) {
+	j.update(id, func(job *Job) {
+		job.State = state
+	})
+}
+
+// Fail moves a job to the failed state and records why
+func (j *JobTracker) Fail(id string, err error) {
+	j.update(id, func(job *Job) {
+		job.State = JobFailed
+		job.Error = err.Error()
+	})
+}
+
+// Get returns a job by its ID
+func (j *JobTracker) Get(id string) (Job, bool) {
+	j.mutex.RLock()
+	defer j.mutex.RUnlock()
+	job, ok := j.jobs[id]
+	return job, ok
+}
+
+func (j *JobTracker) update(id string, change func(job *Job)) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	job, ok := j.jobs[id]
+	if !ok {
+		return
+	}
+	change(&job)
+	job.UpdatedAt = time.Now()
+	j.jobs[id] = job
+}
+
+func (j *JobTracker) prune() {
+	for id, job := range j.jobs {
+		if time.Since(job.UpdatedAt) > jobRetention {
+			delete(j.jobs, id)
+		}
+	}
+}
+
+func newJobID() string {
+	bytes := make([]byte, 8)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
@@ -0,0 +1,71 @@
+package cast
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJobTrackerStates(t *testing.T) {
+	tracker := NewJobTracker()
+	playing := tracker.Create("somestreamer", "192.168.1.1")
+	failed := tracker.Create("otherstreamer", "192.168.1.2")
+	if playing.ID == "" || playing.ID == failed.ID {
+		t.Fatalf("job IDs %q and %q", playing.ID, failed.ID)
+	}
+	if playing.State != JobResolving || playing.Streamer != "somestreamer" || playing.IPAddress != "192.168.1.1" || !playing.CreatedAt.Equal(playing.UpdatedAt) {
+		t.Errorf("created job = %+v", playing)
+	}
+
+	steps := []struct {
+		change func()
+		id     string
+		state  JobState
+		error  string
+	}{
+		{func() { tracker.SetState(playing.ID, JobLoading) }, playing.ID, JobLoading, ""},
+		{func() { tracker.SetState(playing.ID, JobPlaying) }, playing.ID, JobPlaying, ""},
+		{func() { tracker.Fail(failed.ID, errors.New("No playable streams found")) }, failed.ID, JobFailed, "No playable streams found"},
+	}
+	for _, step := range steps {
+		before, _ := tracker.Get(step.id)
+		step.change()
+		job, ok := tracker.Get(step.id)
+		if !ok || job.State != step.state || job.Error != step.error {
+			t.Errorf("job = %+v, %v, want state %s and error %q", job, ok, step.state, step.error)
+		}
+		if job.CreatedAt != before.CreatedAt || job.UpdatedAt.Before(before.UpdatedAt) {
+			t.Errorf("times went from %+v to %+v", before, job)
+		}
+	}
+
+	// Jobs that are no longer tracked are left alone
+	tracker.SetState("missing", JobPlaying)
+	tracker.Fail("missing", errors.New("Gone"))
+	if job, ok := tracker.Get("missing"); ok {
+		t.Errorf("Get() of an unknown job = %+v", job)
+	}
+}
+
+func TestJobTrackerCreatePrunesOldJobs(t *testing.T) {
+	tracker := NewJobTracker()
+	old := tracker.Create("somestreamer", "192.168.1.1")
+	recent := tracker.Create("somestreamer", "192.168.1.1")
+
+	// Only how long ago a job last changed counts, not when it was created
+	tracker.jobs[old.ID] = Job{ID: old.ID, CreatedAt: time.Now().Add(-time.Hour), UpdatedAt: time.Now().Add(-jobRetention - time.Second)}
+	tracker.jobs[recent.ID] = Job{ID: recent.ID, CreatedAt: time.Now().Add(-time.Hour), UpdatedAt: time.Now().Add(-jobRetention + time.Minute)}
+
+	if _, ok := tracker.Get(old.ID); !ok {
+		t.Fatal("the old job was pruned before the next job was created")
+	}
+	created := tracker.Create("somestreamer", "192.168.1.1")
+	for _, test := range []struct {
+		id   string
+		want bool
+	}{{old.ID, false}, {recent.ID, true}, {created.ID, true}} {
+		if _, ok := tracker.Get(test.id); ok != test.want {
+			t.Errorf("tracking job %s = %v, want %v", test.id, ok, test.want)
+		}
+	}
+}
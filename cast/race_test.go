@@ -0,0 +1,7 @@
+//go:build race
+// +build race
+
+package cast
+
+// go-chromecast matches replies to requests in a map it doesn't lock, so real sessions are reported by the race detector
+const raceEnabled = true
@@ -0,0 +1,151 @@
+package cast
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"twitch-caster/models"
+)
+
+const statusPollInterval = 15 * time.Second
+
+// Unreachable devices are polled less often, doubling the wait after every failure up to this long
+const maxStatusBackoff = 5 * time.Minute
+
+// DeviceStatus describes what a Chromecast device is currently doing
+type DeviceStatus struct {
+	Name        string    `json:"name"`
+	IPAddress   string    `json:"ipAddress"`
+	Online      bool      `json:"online"`
+	AppRunning  bool      `json:"appRunning"`
+	AppName     string    `json:"appName"`
+	MediaURL    string    `json:"mediaUrl"`
+	PlayerState string    `json:"playerState"`
+	Streamer    string    `json:"streamer"`
+	Volume      float32   `json:"volume"`
+	Muted       bool      `json:"muted"`
+	Error       string    `json:"error,omitempty"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+}
+
+// StatusPoller periodically queries the status of every configured Chromecast
+type StatusPoller struct {
+	mutex       sync.RWMutex
+	controller  *Controller
+	registry    *Registry
+	chromecasts []models.Chromecast
+	statuses    map[string]DeviceStatus
+	failures    map[string]int
+	retryAt     map[string]time.Time
+}
+
+// NewStatusPoller creates a new StatusPoller object
+func NewStatusPoller(chromecasts []models.Chromecast, controller *Controller, registry *Registry) *StatusPoller {
+	poller := StatusPoller{}
+	poller.chromecasts = chromecasts
+	poller.controller = controller
+	poller.registry = registry
+	poller.statuses = make(map[string]DeviceStatus)
+	poller.failures = make(map[string]int)
+	poller.retryAt = make(map[string]time.Time)
+	return &poller
+}
+
+// Start polls the devices in the background until ctx is cancelled
+func (s *StatusPoller) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(statusPollInterval)
+		defer ticker.Stop()
+
+		for {
+			s.Poll()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Poll queries every device once, in parallel, and saves the results.
+// Devices that failed recently are skipped until their backoff has passed.
+func (s *StatusPoller) Poll() {
+	var wg sync.WaitGroup
+	for _, chromecast := range s.chromecasts {
+		if s.backingOff(chromecast.Name) {
+			continue
+		}
+		wg.Add(1)
+		go func(chromecast models.Chromecast) {
+			defer wg.Done()
+			s.save(chromecast.Name, s.poll(chromecast))
+		}(chromecast)
+	}
+	wg.Wait()
+}
+
+// Statuses returns the last known status of every configured device, in configuration order
+func (s *StatusPoller) Statuses() []DeviceStatus {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	statuses := make([]DeviceStatus, 0, len(s.chromecasts))
+	for _, chromecast := range s.chromecasts {
+		status, ok := s.statuses[chromecast.Name]
+		if !ok {
+			status = DeviceStatus{Name: chromecast.Name, IPAddress: s.registry.Address(chromecast)}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+func (s *StatusPoller) poll(chromecast models.Chromecast) DeviceStatus {
+	ipAddress := s.registry.Address(chromecast)
+	if ipAddress == "" {
+		return DeviceStatus{Name: chromecast.Name, Error: "Device not found on the network", UpdatedAt: time.Now()}
+	}
+
+	status, err := s.controller.Status(ipAddress)
+	status.Name = chromecast.Name
+	status.UpdatedAt = time.Now()
+	if err != nil {
+		status.Error = err.Error()
+	}
+	return status
+}
+
+func (s *StatusPoller) backingOff(name string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return time.Now().Before(s.retryAt[name])
+}
+
+func (s *StatusPoller) save(name string, status DeviceStatus) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.statuses[name] = status
+
+	if status.Error == "" {
+		delete(s.failures, name)
+		delete(s.retryAt, name)
+		return
+	}
+	s.failures[name]++
+	s.retryAt[name] = status.UpdatedAt.Add(statusBackoff(s.failures[name]))
+}
+
+// statusBackoff returns how long to wait before polling a device again after it has failed failures times in a row
+func statusBackoff(failures int) time.Duration {
+	backoff := statusPollInterval
+	for i := 1; i < failures && backoff < maxStatusBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxStatusBackoff {
+		backoff = maxStatusBackoff
+	}
+	return backoff
+}
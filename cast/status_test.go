@@ -0,0 +1,52 @@
+package cast
+
+import (
+	"testing"
+	"time"
+
+	"twitch-caster/models"
+)
+
+func TestStatusBackoff(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 15 * time.Second},
+		{2, 30 * time.Second},
+		{3, time.Minute},
+		{5, 4 * time.Minute},
+		{6, maxStatusBackoff},
+		{100, maxStatusBackoff},
+	}
+	for _, test := range tests {
+		if got := statusBackoff(test.failures); got != test.want {
+			t.Errorf("statusBackoff(%d) = %v, want %v", test.failures, got, test.want)
+		}
+	}
+}
+
+func TestStatusPollerBacksOffUnreachableDevices(t *testing.T) {
+	chromecast := models.Chromecast{Name: "Bedroom", IPAddress: closedAddress(t)}
+	poller := NewStatusPoller([]models.Chromecast{chromecast}, NewController(), NewRegistry(&fakeBrowser{}))
+
+	poller.Poll()
+	first := poller.Statuses()[0]
+	if first.Error == "" {
+		t.Fatal("expected an error for an unreachable device")
+	}
+
+	poller.Poll()
+	if second := poller.Statuses()[0]; !second.UpdatedAt.Equal(first.UpdatedAt) {
+		t.Error("device was polled again before its backoff passed")
+	}
+
+	poller.retryAt[chromecast.Name] = time.Now().Add(-time.Second)
+	poller.Poll()
+	if third := poller.Statuses()[0]; third.UpdatedAt.Equal(first.UpdatedAt) {
+		t.Error("device was not polled after its backoff passed")
+	}
+	if poller.failures[chromecast.Name] != 2 {
+		t.Errorf("failures = %d, want 2", poller.failures[chromecast.Name])
+	}
+}
@@ -7,13 +7,25 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"twitch-caster/models"
+	"twitch-caster/streams"
 )
 
 const configFileName = "configuration.json"
+const defaultTokenFileName = "tokens.dat"
 const defaultChannelListURL = "/gui/twitch-channel-list"
 const defaultCastURL = "/gui/cast/"
+const defaultControlURL = "/gui/control/"
+const defaultStatusURL = "/gui/status"
+const defaultJobsURL = "/gui/jobs/"
+const defaultLoginURL = "/gui/login"
+const defaultAuthCallbackURL = "/gui/auth/callback"
+const defaultCacheURL = "/gui/admin/cache"
+const defaultAPIURL = "/api/v1"
+const defaultEventsURL = "/gui/events"
+const defaultRemoteURL = "/gui/remote"
 
 // Load is used to load the configuration file from disk
 func Load() models.Configuration {
@@ -35,12 +47,18 @@ func Load() models.Configuration {
 	}
 
 	validateConfig(&config)
+
+	if config.Settings.TokenFile == "" {
+		config.Settings.TokenFile = exPath + "/" + defaultTokenFileName
+	}
+	if config.Settings.StaticDir != "" && !filepath.IsAbs(config.Settings.StaticDir) {
+		config.Settings.StaticDir = exPath + "/" + config.Settings.StaticDir
+	}
 	return config
 }
 
 func validateConfig(config *models.Configuration) {
-	if config.Settings.UserID == "" ||
-		config.Settings.TwitchClientID == "" ||
+	if config.Settings.TwitchClientID == "" ||
 		config.Settings.TwitchSecret == "" {
 		log.Fatalln("Error in " + configFileName + ", missing required settings")
 	}
@@ -53,15 +71,61 @@ func validateConfig(config *models.Configuration) {
 		config.Settings.CastURL = defaultCastURL
 	}
 
+	if config.Settings.ControlURL == "" {
+		config.Settings.ControlURL = defaultControlURL
+	}
+
+	if config.Settings.StatusURL == "" {
+		config.Settings.StatusURL = defaultStatusURL
+	}
+
+	if config.Settings.JobsURL == "" {
+		config.Settings.JobsURL = defaultJobsURL
+	}
+
+	if config.Settings.LoginURL == "" {
+		config.Settings.LoginURL = defaultLoginURL
+	}
+
+	if config.Settings.AuthCallbackURL == "" {
+		config.Settings.AuthCallbackURL = defaultAuthCallbackURL
+	}
+
+	if config.Settings.EventsURL == "" {
+		config.Settings.EventsURL = defaultEventsURL
+	}
+
+	if config.Settings.RemoteURL == "" {
+		config.Settings.RemoteURL = defaultRemoteURL
+	}
+
+	if config.Settings.CacheURL == "" {
+		config.Settings.CacheURL = defaultCacheURL
+	}
+
+	if config.Settings.APIURL == "" {
+		config.Settings.APIURL = defaultAPIURL
+	}
+	config.Settings.APIURL = strings.TrimSuffix(config.Settings.APIURL, "/")
+
+	config.Settings.ExternalURL = strings.TrimSuffix(config.Settings.ExternalURL, "/")
+
 	if len(config.Chromecasts) == 0 {
 		log.Fatalln("Error in " + configFileName + ", missing at least one chromecast")
 	}
 
 	for i, chromecast := range config.Chromecasts {
-		if chromecast.IPAddress == "" ||
-			chromecast.Name == "" ||
+		if chromecast.Name == "" ||
 			chromecast.QualityMax == "" {
 			log.Fatalln("Error in " + configFileName + ", Chromecast #" + strconv.Itoa(i) + " missing required settings")
 		}
+
+		if _, ok := streams.ParseQuality(chromecast.QualityMax); !ok {
+			log.Fatalln("Error in " + configFileName + ", Chromecast #" + strconv.Itoa(i) + " has an invalid qualityMax")
+		}
+
+		if minimum, ok := streams.ParseQuality(chromecast.QualityMin); chromecast.QualityMin != "" && (!ok || minimum.Alias != "") {
+			log.Fatalln("Error in " + configFileName + ", Chromecast #" + strconv.Itoa(i) + " has an invalid qualityMin")
+		}
 	}
 }
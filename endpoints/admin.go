@@ -0,0 +1,46 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"twitch-caster/services"
+)
+
+type cacheJSONResponse struct {
+	Caches    map[string]services.CacheStats `json:"caches"`
+	RateLimit services.RateLimitBudget       `json:"rateLimit"`
+}
+
+// AdminEndpoint contains the endpoints for inspecting the server
+type AdminEndpoint struct {
+	twitchService *services.TwitchService
+}
+
+// NewAdminEndpoint creates a new AdminEndpoint object
+func NewAdminEndpoint(twitchService *services.TwitchService) *AdminEndpoint {
+	adminEndpoint := AdminEndpoint{}
+	adminEndpoint.twitchService = twitchService
+	return &adminEndpoint
+}
+
+// Cache is the entry point for an HTTP request for the Twitch cache statistics, a JSON POST flushes the cache first
+func (a *AdminEndpoint) Cache(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		if !requireJSON(w, r) {
+			return
+		}
+		a.twitchService.FlushCache()
+		fmt.Println("Flushed the Twitch cache")
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cacheJSONResponse{a.twitchService.CacheStats(), a.twitchService.RateLimitBudget()})
+}
@@ -0,0 +1,69 @@
+package endpoints
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"twitch-caster/auth"
+	"twitch-caster/services"
+)
+
+func TestAdminCacheFlush(t *testing.T) {
+	config := testConfig(true)
+	helix := newFakeHelix(t)
+	authManager := auth.NewManagerWithURL(config.Settings, auth.NewMemoryTokenStore(), helix.URL)
+	twitchService := services.NewTwitchServiceWithClient(config.Settings, authManager, helix.Client(), helix.URL)
+	adminEndpoint := NewAdminEndpoint(twitchService)
+
+	// Looking up the followed stream caches its game
+	if snapshot := services.NewStreamPoller(twitchService).Poll(context.Background()); snapshot.Err != nil {
+		t.Fatal(snapshot.Err)
+	}
+
+	cache := func(method string, contentType string) (int, cacheJSONResponse) {
+		request := httptest.NewRequest(method, "/gui/admin/cache", nil)
+		if contentType != "" {
+			request.Header.Set("Content-Type", contentType)
+		}
+		recorder := httptest.NewRecorder()
+		adminEndpoint.Cache(recorder, request)
+		var response cacheJSONResponse
+		if recorder.Code == http.StatusOK {
+			decodeTestJSON(t, recorder, &response)
+		}
+		return recorder.Code, response
+	}
+
+	if status, response := cache(http.MethodGet, ""); status != http.StatusOK || response.Caches["games"].Entries != 1 {
+		t.Fatalf("status = %d, caches %+v", status, response.Caches)
+	}
+
+	// A form on another site can't flush the cache
+	for _, contentType := range []string{"", "text/plain", "application/x-www-form-urlencoded"} {
+		if status, _ := cache(http.MethodPost, contentType); status != http.StatusUnsupportedMediaType {
+			t.Errorf("POST with Content-Type %q status = %d, want 415", contentType, status)
+		}
+	}
+	if twitchService.CacheStats()["games"].Entries != 1 {
+		t.Error("the cache was flushed by a request that was refused")
+	}
+
+	status, response := cache(http.MethodPost, "application/json")
+	if status != http.StatusOK {
+		t.Fatalf("status = %d", status)
+	}
+	for name, stats := range response.Caches {
+		if stats.Entries != 0 {
+			t.Errorf("%s cache has %d entries after flushing", name, stats.Entries)
+		}
+	}
+	if _, ok := response.Caches["follows"]; !ok {
+		t.Errorf("caches %v don't include the follows list", response.Caches)
+	}
+
+	if status, _ := cache(http.MethodDelete, ""); status != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE status = %d, want 405", status)
+	}
+}
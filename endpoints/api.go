@@ -0,0 +1,211 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"twitch-caster/cast"
+	"twitch-caster/models"
+	"twitch-caster/services"
+)
+
+// Cast requests are small, anything bigger isn't one
+const maxAPIRequestSize = 1 << 16
+
+type apiError struct {
+	Error apiErrorBody `json:"error"`
+}
+
+type apiErrorBody struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+type streamsJSONResponse struct {
+	Streams   []models.OnlineStreamer `json:"streams"`
+	UpdatedAt time.Time               `json:"updatedAt"`
+	Error     string                  `json:"error,omitempty"`
+}
+
+type deviceJSON struct {
+	Name        string            `json:"name"`
+	IPAddress   string            `json:"ipAddress"`
+	QualityMax  string            `json:"qualityMax"`
+	QualityMin  string            `json:"qualityMin,omitempty"`
+	Prefer60FPS bool              `json:"prefer60fps"`
+	AudioOnly   bool              `json:"audioOnly"`
+	Status      cast.DeviceStatus `json:"status"`
+}
+
+type devicesJSONResponse struct {
+	Devices []deviceJSON `json:"devices"`
+}
+
+type castJSONRequest struct {
+	Stream string `json:"stream"`
+	// Device is the name or IP address of a configured Chromecast
+	Device string `json:"device"`
+}
+
+// APIEndpoint contains the versioned JSON API, for scripts and other frontends
+type APIEndpoint struct {
+	twitchEndpoint *TwitchEndpoint
+	apiURL         string
+}
+
+// NewAPIEndpoint creates a new APIEndpoint object served under apiURL that shares the devices and cast jobs of twitchEndpoint
+func NewAPIEndpoint(twitchEndpoint *TwitchEndpoint, apiURL string) *APIEndpoint {
+	apiEndpoint := APIEndpoint{}
+	apiEndpoint.twitchEndpoint = twitchEndpoint
+	apiEndpoint.apiURL = apiURL
+	return &apiEndpoint
+}
+
+// routes maps the path of every API route under apiURL to its handler, paths ending in a slash take an ID
+func (a *APIEndpoint) routes() map[string]http.HandlerFunc {
+	return map[string]http.HandlerFunc{
+		"/streams":      a.Streams,
+		"/devices":      a.Devices,
+		"/cast":         a.Cast,
+		"/jobs/":        a.Job,
+		"/openapi.json": a.OpenAPI,
+	}
+}
+
+// Register routes the API under apiURL on mux
+func (a *APIEndpoint) Register(mux *http.ServeMux) {
+	for path, handler := range a.routes() {
+		mux.HandleFunc(a.apiURL+path, handler)
+	}
+}
+
+// Streams is the entry point for GET /api/v1/streams, the followed streams that are live
+func (a *APIEndpoint) Streams(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	snapshot := a.twitchEndpoint.streamPoller.Snapshot()
+	if snapshot.UpdatedAt.IsZero() || snapshot.Err == services.ErrNotLoggedIn {
+		snapshot = a.twitchEndpoint.streamPoller.Poll(r.Context())
+	}
+	if snapshot.Err == services.ErrNotLoggedIn {
+		writeAPIError(w, http.StatusUnauthorized, snapshot.Err.Error())
+		return
+	}
+	if snapshot.UpdatedAt.IsZero() {
+		writeAPIError(w, http.StatusBadGateway, "Unable to fetch streams from Twitch: "+snapshot.Err.Error())
+		return
+	}
+
+	response := streamsJSONResponse{Streams: snapshot.Streamers, UpdatedAt: snapshot.UpdatedAt}
+	if snapshot.Streamers == nil {
+		response.Streams = []models.OnlineStreamer{}
+	}
+	if snapshot.Err != nil {
+		response.Error = snapshot.Err.Error()
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Devices is the entry point for GET /api/v1/devices, the configured Chromecasts and their status
+func (a *APIEndpoint) Devices(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	statuses := a.twitchEndpoint.statusPoller.Statuses()
+	devices := make([]deviceJSON, 0, len(a.twitchEndpoint.chromecasts))
+	for i, chromecast := range a.twitchEndpoint.chromecasts {
+		devices = append(devices, deviceJSON{
+			Name:        chromecast.Name,
+			IPAddress:   a.twitchEndpoint.registry.Address(chromecast),
+			QualityMax:  chromecast.QualityMax,
+			QualityMin:  chromecast.QualityMin,
+			Prefer60FPS: chromecast.Prefer60FPS,
+			AudioOnly:   chromecast.AudioOnly,
+			Status:      statuses[i],
+		})
+	}
+	writeJSON(w, http.StatusOK, devicesJSONResponse{devices})
+}
+
+// Cast is the entry point for POST /api/v1/cast, it starts a cast job and responds with it
+func (a *APIEndpoint) Cast(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) || !requireJSON(w, r) {
+		return
+	}
+
+	var request castJSONRequest
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAPIRequestSize)).Decode(&request)
+	if err != nil {
+		writeAPIError(w, http.StatusBadRequest, "Invalid JSON body")
+		return
+	}
+	request.Stream = strings.TrimSpace(request.Stream)
+	if request.Stream == "" || request.Device == "" {
+		writeAPIError(w, http.StatusBadRequest, "stream and device are required")
+		return
+	}
+
+	chromecast, ok := a.twitchEndpoint.findDevice(request.Device)
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "Unknown Chromecast device "+request.Device)
+		return
+	}
+	job, err := a.twitchEndpoint.StartCast(request.Stream, chromecast)
+	if err != nil {
+		writeAPIError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusAccepted, job)
+}
+
+// Job is the entry point for GET /api/v1/jobs/<id>, the state of a cast job
+func (a *APIEndpoint) Job(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	var pathParams = strings.Split(r.URL.Path, "/")
+	job, ok := a.twitchEndpoint.jobTracker.Get(pathParams[len(pathParams)-1])
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "Unknown cast job")
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// allowMethod responds with 405 and returns false unless the request uses method
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method == method || method == http.MethodGet && r.Method == http.MethodHead {
+		return true
+	}
+	w.Header().Set("Allow", method)
+	writeAPIError(w, http.StatusMethodNotAllowed, "Method "+r.Method+" not allowed")
+	return false
+}
+
+// requireJSON rejects bodies that aren't sent as JSON. A form on another site can post a text/plain body that parses as JSON,
+// but browsers won't let it send application/json without asking this server first.
+func requireJSON(w http.ResponseWriter, r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil && mediaType == "application/json" {
+		return true
+	}
+	writeAPIError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/json")
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, apiError{apiErrorBody{status, message}})
+}
@@ -0,0 +1,215 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"twitch-caster/cast"
+)
+
+func serveAPI(handler http.HandlerFunc, method string, target string, body string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(method, target, strings.NewReader(body))
+	if method == http.MethodPost {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	recorder := httptest.NewRecorder()
+	handler(recorder, request)
+	return recorder
+}
+
+// checkAPIError checks a response is an apiError with the given status whose message contains message
+func checkAPIError(t *testing.T, recorder *httptest.ResponseRecorder, status int, message string) {
+	t.Helper()
+	if recorder.Code != status {
+		t.Errorf("status = %d, want %d", recorder.Code, status)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type = %q", contentType)
+	}
+	var body apiError
+	decodeTestJSON(t, recorder, &body)
+	if body.Error.Status != status || !strings.Contains(body.Error.Message, message) {
+		t.Errorf("error = %+v, want status %d and a message containing %q", body.Error, status, message)
+	}
+}
+
+func TestAPIMethodNotAllowed(t *testing.T) {
+	api := NewAPIEndpoint(newTestTwitchEndpoint(t, testConfig(true)), "/api/v1")
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		target  string
+		allow   string
+	}{
+		{"get cast", api.Cast, http.MethodGet, "/api/v1/cast", http.MethodPost},
+		{"put cast", api.Cast, http.MethodPut, "/api/v1/cast", http.MethodPost},
+		{"post streams", api.Streams, http.MethodPost, "/api/v1/streams", http.MethodGet},
+		{"delete devices", api.Devices, http.MethodDelete, "/api/v1/devices", http.MethodGet},
+		{"post job", api.Job, http.MethodPost, "/api/v1/jobs/abc", http.MethodGet},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serveAPI(test.handler, test.method, test.target, "")
+			checkAPIError(t, recorder, http.StatusMethodNotAllowed, "Method "+test.method+" not allowed")
+			if allow := recorder.Header().Get("Allow"); allow != test.allow {
+				t.Errorf("Allow = %q, want %q", allow, test.allow)
+			}
+		})
+	}
+}
+
+func TestAPICastErrors(t *testing.T) {
+	api := NewAPIEndpoint(newTestTwitchEndpoint(t, testConfig(true)), "/api/v1")
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		message string
+	}{
+		{"invalid json", `{"stream":`, http.StatusBadRequest, "Invalid JSON body"},
+		{"wrong type", `{"stream":1,"device":"Living Room"}`, http.StatusBadRequest, "Invalid JSON body"},
+		{"too large", `{"stream":"` + strings.Repeat("a", maxAPIRequestSize) + `","device":"Living Room"}`, http.StatusBadRequest, "Invalid JSON body"},
+		{"no stream", `{"device":"Living Room"}`, http.StatusBadRequest, "stream and device are required"},
+		{"blank stream", `{"stream":"  ","device":"Living Room"}`, http.StatusBadRequest, "stream and device are required"},
+		{"no device", `{"stream":"somestreamer"}`, http.StatusBadRequest, "stream and device are required"},
+		{"unknown device", `{"stream":"somestreamer","device":"Bedroom"}`, http.StatusNotFound, "Unknown Chromecast device Bedroom"},
+		{"not discovered", `{"stream":"somestreamer","device":"Kitchen"}`, http.StatusServiceUnavailable, "Chromecast device Kitchen has not been found on the network"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serveAPI(api.Cast, http.MethodPost, "/api/v1/cast", test.body)
+			checkAPIError(t, recorder, test.status, test.message)
+		})
+	}
+}
+
+// A form on another site can post a body that parses as JSON, but not with a JSON Content-Type
+func TestAPICastRequiresJSON(t *testing.T) {
+	api := NewAPIEndpoint(newTestTwitchEndpoint(t, testConfig(true)), "/api/v1")
+	tests := []struct {
+		contentType string
+		status      int
+	}{
+		{"", http.StatusUnsupportedMediaType},
+		{"text/plain", http.StatusUnsupportedMediaType},
+		{"application/x-www-form-urlencoded", http.StatusUnsupportedMediaType},
+		{"multipart/form-data; boundary=x", http.StatusUnsupportedMediaType},
+		{"application/json; charset=utf-8", http.StatusAccepted},
+	}
+	for _, test := range tests {
+		t.Run(test.contentType, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/v1/cast", strings.NewReader(`{"stream":"somestreamer","device":"Living Room"}`))
+			if test.contentType != "" {
+				request.Header.Set("Content-Type", test.contentType)
+			}
+			recorder := httptest.NewRecorder()
+			api.Cast(recorder, request)
+			if test.status != http.StatusAccepted {
+				checkAPIError(t, recorder, test.status, "Content-Type must be application/json")
+			} else if recorder.Code != test.status {
+				t.Errorf("status = %d, body %s", recorder.Code, recorder.Body)
+			}
+		})
+	}
+}
+
+// waitForJob polls the job endpoint until the job leaves the resolving state
+func waitForJob(t *testing.T, api *APIEndpoint, id string) cast.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		recorder := serveAPI(api.Job, http.MethodGet, "/api/v1/jobs/"+id, "")
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+		}
+		var job cast.Job
+		decodeTestJSON(t, recorder, &job)
+		if job.State != cast.JobResolving || time.Now().After(deadline) {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAPICastAccepted(t *testing.T) {
+	api := NewAPIEndpoint(newTestTwitchEndpoint(t, testConfig(true)), "/api/v1")
+
+	// Devices can be given by name in any case or by address
+	for _, device := range []string{"Living Room", "living room", "192.168.1.1"} {
+		t.Run(device, func(t *testing.T) {
+			recorder := serveAPI(api.Cast, http.MethodPost, "/api/v1/cast", `{"stream":" somestreamer ","device":"`+device+`"}`)
+			if recorder.Code != http.StatusAccepted {
+				t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+			}
+			var job cast.Job
+			decodeTestJSON(t, recorder, &job)
+			if job.ID == "" || job.Streamer != "somestreamer" || job.IPAddress != "192.168.1.1" || job.State != cast.JobResolving {
+				t.Errorf("job = %+v", job)
+			}
+
+			// The resolver fails every stream, which the job reports
+			finished := waitForJob(t, api, job.ID)
+			if finished.State != cast.JobFailed || !strings.Contains(finished.Error, "can't be resolved") {
+				t.Errorf("finished job = %+v", finished)
+			}
+		})
+	}
+}
+
+func TestAPIUnknownJob(t *testing.T) {
+	api := NewAPIEndpoint(newTestTwitchEndpoint(t, testConfig(true)), "/api/v1")
+	recorder := serveAPI(api.Job, http.MethodGet, "/api/v1/jobs/missing", "")
+	checkAPIError(t, recorder, http.StatusNotFound, "Unknown cast job")
+}
+
+func TestAPIStreams(t *testing.T) {
+	api := NewAPIEndpoint(newTestTwitchEndpoint(t, testConfig(true)), "/api/v1")
+	recorder := serveAPI(api.Streams, http.MethodGet, "/api/v1/streams", "")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+	}
+	var response streamsJSONResponse
+	decodeTestJSON(t, recorder, &response)
+	if len(response.Streams) != 1 || response.UpdatedAt.IsZero() || response.Error != "" {
+		t.Fatalf("response = %+v", response)
+	}
+	streamer := response.Streams[0]
+	if streamer.Login != "somestreamer" || streamer.Name != "SomeStreamer" || streamer.Game != "Some Game" || streamer.ViewerCount != 42 {
+		t.Errorf("streamer = %+v", streamer)
+	}
+}
+
+func TestAPIStreamsNotLoggedIn(t *testing.T) {
+	api := NewAPIEndpoint(newTestTwitchEndpoint(t, testConfig(false)), "/api/v1")
+	recorder := serveAPI(api.Streams, http.MethodGet, "/api/v1/streams", "")
+	checkAPIError(t, recorder, http.StatusUnauthorized, "")
+}
+
+func TestAPIDevices(t *testing.T) {
+	api := NewAPIEndpoint(newTestTwitchEndpoint(t, testConfig(true)), "/api/v1")
+	for _, method := range []string{http.MethodGet, http.MethodHead} {
+		recorder := serveAPI(api.Devices, method, "/api/v1/devices", "")
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("%s status = %d", method, recorder.Code)
+		}
+	}
+
+	recorder := serveAPI(api.Devices, http.MethodGet, "/api/v1/devices", "")
+	var response devicesJSONResponse
+	decodeTestJSON(t, recorder, &response)
+	if len(response.Devices) != 2 {
+		t.Fatalf("devices = %+v", response.Devices)
+	}
+	livingRoom, kitchen := response.Devices[0], response.Devices[1]
+	if livingRoom.Name != "Living Room" || livingRoom.IPAddress != "192.168.1.1" || livingRoom.QualityMax != "best" {
+		t.Errorf("living room = %+v", livingRoom)
+	}
+	// The kitchen has no configured address and hasn't been discovered
+	if kitchen.Name != "Kitchen" || kitchen.IPAddress != "" || kitchen.QualityMin != "360p" || !kitchen.Prefer60FPS {
+		t.Errorf("kitchen = %+v", kitchen)
+	}
+}
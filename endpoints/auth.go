@@ -0,0 +1,85 @@
+package endpoints
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"twitch-caster/auth"
+	"twitch-caster/models"
+)
+
+const stateCookieName = "twitch_caster_oauth_state"
+
+// AuthEndpoint contains the endpoints for logging in with a Twitch account
+type AuthEndpoint struct {
+	settings    models.Settings
+	authManager *auth.Manager
+}
+
+// NewAuthEndpoint creates a new AuthEndpoint object
+func NewAuthEndpoint(settings models.Settings, authManager *auth.Manager) *AuthEndpoint {
+	authEndpoint := AuthEndpoint{}
+	authEndpoint.settings = settings
+	authEndpoint.authManager = authManager
+	return &authEndpoint
+}
+
+// Login is the entry point for an HTTP login request, it redirects to Twitch to authorize the app
+func (a *AuthEndpoint) Login(w http.ResponseWriter, r *http.Request) {
+	stateBytes := make([]byte, 16)
+	if _, err := rand.Read(stateBytes); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Println("Error generating OAuth state: ", err)
+		return
+	}
+	state := hex.EncodeToString(stateBytes)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     stateCookieName,
+		Value:    state,
+		Path:     a.settings.AuthCallbackURL,
+		MaxAge:   600,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, a.authManager.AuthorizeURL(a.redirectURI(r), state), http.StatusFound)
+}
+
+// AuthCallback is the entry point Twitch redirects back to after the user authorizes the app
+func (a *AuthEndpoint) AuthCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if errorDescription := query.Get("error_description"); errorDescription != "" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Twitch login failed: %s", errorDescription)
+		return
+	}
+
+	stateCookie, err := r.Cookie(stateCookieName)
+	if err != nil || stateCookie.Value == "" || stateCookie.Value != query.Get("state") {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "Invalid login state, please try logging in again")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{Name: stateCookieName, Path: a.settings.AuthCallbackURL, MaxAge: -1})
+
+	err = a.authManager.ExchangeCode(r.Context(), query.Get("code"), a.redirectURI(r))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Println("Error exchanging OAuth code: ", err)
+		fmt.Fprintf(w, "Twitch login failed")
+		return
+	}
+
+	fmt.Println("Logged in to Twitch as", a.authManager.UserLogin())
+	http.Redirect(w, r, a.settings.ChannelListURL, http.StatusFound)
+}
+
+// redirectURI is the callback URL Twitch sends the user back to, it must be registered with the Twitch application
+func (a *AuthEndpoint) redirectURI(r *http.Request) string {
+	if a.settings.ExternalURL != "" {
+		return a.settings.ExternalURL + a.settings.AuthCallbackURL
+	}
+	return "http://" + r.Host + a.settings.AuthCallbackURL
+}
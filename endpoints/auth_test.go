@@ -0,0 +1,88 @@
+package endpoints
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"twitch-caster/auth"
+)
+
+// newTestAuthEndpoint creates an AuthEndpoint that logs in with the fake Helix, along with its Manager
+func newTestAuthEndpoint(t *testing.T) (*AuthEndpoint, *auth.Manager) {
+	config := testConfig(false)
+	authManager := auth.NewManagerWithURL(config.Settings, auth.NewMemoryTokenStore(), newFakeHelix(t).URL)
+	return NewAuthEndpoint(config.Settings, authManager), authManager
+}
+
+// stateCookie returns the state cookie set by a response, or nil
+func stateCookie(recorder *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.Name == stateCookieName {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func TestAuthLoginThenCallback(t *testing.T) {
+	authEndpoint, authManager := newTestAuthEndpoint(t)
+
+	recorder := httptest.NewRecorder()
+	authEndpoint.Login(recorder, httptest.NewRequest(http.MethodGet, "/gui/login", nil))
+	cookie := stateCookie(recorder)
+	if recorder.Code != http.StatusFound || cookie == nil || cookie.Value == "" || !cookie.HttpOnly || cookie.Path != "/gui/auth/callback" {
+		t.Fatalf("status = %d, state cookie %+v", recorder.Code, cookie)
+	}
+	location, _ := url.Parse(recorder.Header().Get("Location"))
+	if state := location.Query().Get("state"); state != cookie.Value {
+		t.Fatalf("redirected with state %q, cookie has %q", state, cookie.Value)
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/gui/auth/callback?code=good-code&state="+cookie.Value, nil)
+	request.AddCookie(cookie)
+	recorder = httptest.NewRecorder()
+	authEndpoint.AuthCallback(recorder, request)
+	if recorder.Code != http.StatusFound || recorder.Header().Get("Location") != "/gui/twitch-channel-list" {
+		t.Errorf("status = %d, redirected to %q", recorder.Code, recorder.Header().Get("Location"))
+	}
+	// The state can only be used once
+	if cleared := stateCookie(recorder); cleared == nil || cleared.MaxAge >= 0 || cleared.Path != "/gui/auth/callback" {
+		t.Errorf("state cookie %+v, want it cleared", cleared)
+	}
+	if !authManager.HasUserToken() || authManager.UserLogin() != "someone" {
+		t.Errorf("logged in as %q, has user token %v", authManager.UserLogin(), authManager.HasUserToken())
+	}
+}
+
+func TestAuthCallbackInvalidState(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		cookie string
+	}{
+		{"no cookie", "code=good-code&state=abc", ""},
+		{"no state", "code=good-code", "abc"},
+		{"different state", "code=good-code&state=abd", "abc"},
+		{"empty state", "code=good-code&state=", ""},
+		{"denied", "error=access_denied&error_description=The+user+denied+you+access&state=abc", "abc"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			authEndpoint, authManager := newTestAuthEndpoint(t)
+			request := httptest.NewRequest(http.MethodGet, "/gui/auth/callback?"+test.query, nil)
+			if test.cookie != "" {
+				request.AddCookie(&http.Cookie{Name: stateCookieName, Value: test.cookie})
+			}
+			recorder := httptest.NewRecorder()
+			authEndpoint.AuthCallback(recorder, request)
+			if recorder.Code != http.StatusBadRequest {
+				t.Errorf("status = %d, want 400", recorder.Code)
+			}
+			if authManager.HasUserToken() {
+				t.Error("logged in without a matching state")
+			}
+		})
+	}
+}
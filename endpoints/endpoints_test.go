@@ -0,0 +1,111 @@
+package endpoints
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vishen/go-chromecast/dns"
+
+	"twitch-caster/auth"
+	"twitch-caster/cast"
+	"twitch-caster/models"
+	"twitch-caster/services"
+	"twitch-caster/static"
+	"twitch-caster/streams"
+)
+
+// noBrowser never finds any devices, so only configured addresses are known
+type noBrowser struct{}
+
+func (noBrowser) Browse(ctx context.Context) (<-chan dns.CastEntry, error) {
+	entries := make(chan dns.CastEntry)
+	close(entries)
+	return entries, nil
+}
+
+// failingResolver fails every stream so cast jobs end before contacting a device
+type failingResolver struct{}
+
+func (failingResolver) Resolve(request streams.Request) (streams.Result, error) {
+	return streams.Result{}, errors.New("Streams can't be resolved in tests")
+}
+
+// testConfig has the URLs of configuration.json, a Chromecast with an address and one that hasn't been discovered
+func testConfig(loggedIn bool) models.Configuration {
+	config := models.Configuration{
+		Settings: models.Settings{
+			TwitchClientID:  "client-id",
+			TwitchSecret:    "secret",
+			ChannelListURL:  "/gui/twitch-channel-list",
+			CastURL:         "/gui/cast/",
+			ControlURL:      "/gui/control/",
+			StatusURL:       "/gui/status",
+			JobsURL:         "/gui/jobs/",
+			LoginURL:        "/gui/login",
+			AuthCallbackURL: "/gui/auth/callback",
+			EventsURL:       "/gui/events",
+			RemoteURL:       "/gui/remote",
+			CacheURL:        "/gui/admin/cache",
+			APIURL:          "/api/v1",
+		},
+		Chromecasts: []models.Chromecast{
+			{Name: "Living Room", IPAddress: "192.168.1.1", QualityMax: "best"},
+			{Name: "Kitchen", QualityMax: "720p", QualityMin: "360p", Prefer60FPS: true},
+		},
+	}
+	if loggedIn {
+		config.Settings.TwitchRefreshToken = "refresh-token"
+	}
+	return config
+}
+
+// newFakeHelix serves a single followed stream along with its game and profile image, to user 1 who is called someone
+func newFakeHelix(t *testing.T) *httptest.Server {
+	helix := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/oauth2/token":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"access_token": "token", "refresh_token": "refresh-token", "expires_in": 3600})
+		case "/oauth2/validate":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"client_id": "client-id", "login": "someone", "user_id": "1"})
+		case "/streams/followed":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"data": []map[string]interface{}{
+				{"user_id": "2", "user_login": "somestreamer", "user_name": "SomeStreamer", "game_id": "3", "title": "Speedruns", "viewer_count": 42},
+			}})
+		case "/games":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"data": []map[string]interface{}{{"id": "3", "name": "Some Game"}}})
+		case "/users":
+			writeJSON(w, http.StatusOK, map[string]interface{}{"data": []map[string]interface{}{
+				{"id": "2", "login": "somestreamer", "profile_image_url": "https://example.com/2.png"},
+			}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(helix.Close)
+	return helix
+}
+
+// newTestTwitchEndpoint wires a TwitchEndpoint together the way main.go does, talking to a fake Helix
+func newTestTwitchEndpoint(t *testing.T, config models.Configuration) *TwitchEndpoint {
+	helix := newFakeHelix(t)
+	authManager := auth.NewManagerWithURL(config.Settings, auth.NewMemoryTokenStore(), helix.URL)
+	twitchService := services.NewTwitchServiceWithClient(config.Settings, authManager, helix.Client(), helix.URL)
+
+	castController := cast.NewController()
+	registry := cast.NewRegistry(noBrowser{})
+	statusPoller := cast.NewStatusPoller(config.Chromecasts, castController, registry)
+	staticEndpoint := NewStaticEndpoint(static.Files, "", "/static/")
+	return NewTwitchEndpoint(config, services.NewStreamPoller(twitchService), failingResolver{}, castController, registry, statusPoller, staticEndpoint)
+}
+
+// decodeTestJSON decodes the body of a recorded response into v
+func decodeTestJSON(t *testing.T, recorder *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(recorder.Body).Decode(v); err != nil {
+		t.Fatalf("decoding %q: %v", recorder.Body.String(), err)
+	}
+}
@@ -0,0 +1,87 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"twitch-caster/models"
+)
+
+// Proxies drop connections that are quiet for too long, so a comment is sent when there is nothing else
+const eventKeepAlive = 30 * time.Second
+
+type snapshotEvent struct {
+	Streams   []models.OnlineStreamer `json:"streams"`
+	UpdatedAt time.Time               `json:"updatedAt"`
+	Error     string                  `json:"error,omitempty"`
+}
+
+type refreshedEvent struct {
+	UpdatedAt time.Time `json:"updatedAt"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// StreamEvents is the entry point for the Server-Sent Events stream of changes to the channel list.
+// A snapshot event with every stream is sent first, then added, removed and updated events as streams change,
+// followed by a refreshed event after every refresh.
+func (t *TwitchEndpoint) StreamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Println("Error: Streaming responses are not supported")
+		return
+	}
+
+	updates, unsubscribe := t.streamPoller.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	snapshot := t.streamPoller.Snapshot()
+	initial := snapshotEvent{Streams: snapshot.Streamers, UpdatedAt: snapshot.UpdatedAt}
+	if initial.Streams == nil {
+		initial.Streams = []models.OnlineStreamer{}
+	}
+	if snapshot.Err != nil {
+		initial.Error = snapshot.Err.Error()
+	}
+	writeEvent(w, "snapshot", initial)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprintf(w, ": keep-alive\n\n")
+		case update, ok := <-updates:
+			if !ok {
+				// Too far behind, the browser reconnects and starts over from a new snapshot
+				return
+			}
+			for _, event := range update.Events {
+				writeEvent(w, event.Type, event.Streamer)
+			}
+			refreshed := refreshedEvent{UpdatedAt: update.Snapshot.UpdatedAt}
+			if update.Snapshot.Err != nil {
+				refreshed.Error = update.Snapshot.Err.Error()
+			}
+			writeEvent(w, "refreshed", refreshed)
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes a single Server-Sent Event, JSON never contains a newline so data fits on one line
+func writeEvent(w http.ResponseWriter, name string, data interface{}) {
+	body, _ := json.Marshal(data)
+	fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, body)
+}
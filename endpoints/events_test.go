@@ -0,0 +1,96 @@
+package endpoints
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"twitch-caster/models"
+)
+
+// readEvent reads the next Server-Sent Event, skipping comments
+func readEvent(t *testing.T, reader *bufio.Reader) (string, string) {
+	t.Helper()
+	var name, data string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case line == "" && name != "":
+			return name, data
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			if data != "" {
+				t.Fatalf("event %s has more than one data line", name)
+			}
+			data = strings.TrimPrefix(line, "data: ")
+		case line != "" && !strings.HasPrefix(line, ":"):
+			t.Fatalf("unexpected line %q", line)
+		}
+	}
+}
+
+func TestStreamEvents(t *testing.T) {
+	twitchEndpoint := newTestTwitchEndpoint(t, testConfig(true))
+	server := httptest.NewServer(http.HandlerFunc(twitchEndpoint.StreamEvents))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	request, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	headers := map[string]string{"Content-Type": "text/event-stream", "Cache-Control": "no-cache", "X-Accel-Buffering": "no"}
+	for header, want := range headers {
+		if got := response.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	// Nothing has been polled yet, so the snapshot is empty. It arrives while the response is still open, so it was flushed.
+	reader := bufio.NewReader(response.Body)
+	name, data := readEvent(t, reader)
+	if name != "snapshot" || data != `{"streams":[],"updatedAt":"0001-01-01T00:00:00Z"}` {
+		t.Errorf("first event %s: %s", name, data)
+	}
+	if count := twitchEndpoint.streamPoller.SubscriberCount(); count != 1 {
+		t.Fatalf("%d subscribers, want 1", count)
+	}
+
+	twitchEndpoint.streamPoller.Poll(context.Background())
+	name, data = readEvent(t, reader)
+	var streamer models.OnlineStreamer
+	json.Unmarshal([]byte(data), &streamer)
+	if name != "added" || streamer.Login != "somestreamer" || streamer.Game != "Some Game" {
+		t.Errorf("event %s: %s", name, data)
+	}
+	name, data = readEvent(t, reader)
+	var refreshed refreshedEvent
+	json.Unmarshal([]byte(data), &refreshed)
+	if name != "refreshed" || refreshed.UpdatedAt.IsZero() || refreshed.Error != "" {
+		t.Errorf("event %s: %s", name, data)
+	}
+
+	// Closing the page unsubscribes it
+	cancel()
+	deadline := time.Now().Add(2 * time.Second)
+	for twitchEndpoint.streamPoller.SubscriberCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("still subscribed after the request was cancelled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
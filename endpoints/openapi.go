@@ -0,0 +1,211 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// openAPISpec describes the JSON API, paths are relative to the server URL filled in when it is served
+const openAPISpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "TwitchCaster API",
+    "version": "1.0.0",
+    "description": "Lists followed Twitch streams and casts them to Chromecast devices"
+  },
+  "paths": {
+    "/streams": {
+      "get": {
+        "summary": "List the followed streams that are live",
+        "operationId": "listStreams",
+        "responses": {
+          "200": {
+            "description": "The streams as of the last refresh",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/StreamList"}}}
+          },
+          "401": {"$ref": "#/components/responses/Error"},
+          "502": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/devices": {
+      "get": {
+        "summary": "List the configured Chromecasts and their status",
+        "operationId": "listDevices",
+        "responses": {
+          "200": {
+            "description": "Every configured device, in configuration order",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/DeviceList"}}}
+          }
+        }
+      }
+    },
+    "/cast": {
+      "post": {
+        "summary": "Cast a stream to a Chromecast",
+        "operationId": "castStream",
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/CastRequest"}}}
+        },
+        "responses": {
+          "202": {
+            "description": "The cast job that was started",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Job"}}}
+          },
+          "400": {"$ref": "#/components/responses/Error"},
+          "404": {"$ref": "#/components/responses/Error"},
+          "415": {"$ref": "#/components/responses/Error"},
+          "503": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/jobs/{id}": {
+      "get": {
+        "summary": "Get the state of a cast job",
+        "operationId": "getJob",
+        "parameters": [
+          {"name": "id", "in": "path", "required": true, "schema": {"type": "string"}}
+        ],
+        "responses": {
+          "200": {
+            "description": "The cast job",
+            "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Job"}}}
+          },
+          "404": {"$ref": "#/components/responses/Error"}
+        }
+      }
+    },
+    "/openapi.json": {
+      "get": {
+        "summary": "Get this OpenAPI document",
+        "operationId": "getOpenAPI",
+        "responses": {
+          "200": {
+            "description": "The OpenAPI 3 document describing the API",
+            "content": {"application/json": {"schema": {"type": "object"}}}
+          }
+        }
+      }
+    }
+  },
+  "components": {
+    "responses": {
+      "Error": {
+        "description": "The request failed",
+        "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Error"}}}
+      }
+    },
+    "schemas": {
+      "Error": {
+        "type": "object",
+        "required": ["error"],
+        "properties": {
+          "error": {
+            "type": "object",
+            "required": ["status", "message"],
+            "properties": {
+              "status": {"type": "integer"},
+              "message": {"type": "string"}
+            }
+          }
+        }
+      },
+      "Stream": {
+        "type": "object",
+        "properties": {
+          "userId": {"type": "string"},
+          "login": {"type": "string"},
+          "name": {"type": "string"},
+          "game": {"type": "string"},
+          "profileImageUrl": {"type": "string"},
+          "title": {"type": "string"},
+          "thumbnailUrl": {"type": "string"},
+          "viewerCount": {"type": "integer"}
+        }
+      },
+      "StreamList": {
+        "type": "object",
+        "required": ["streams", "updatedAt"],
+        "properties": {
+          "streams": {"type": "array", "items": {"$ref": "#/components/schemas/Stream"}},
+          "updatedAt": {"type": "string", "format": "date-time"},
+          "error": {"type": "string", "description": "Why the last refresh failed, the streams are from an earlier one"}
+        }
+      },
+      "DeviceStatus": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string"},
+          "ipAddress": {"type": "string"},
+          "online": {"type": "boolean"},
+          "appRunning": {"type": "boolean"},
+          "appName": {"type": "string"},
+          "mediaUrl": {"type": "string"},
+          "playerState": {"type": "string"},
+          "streamer": {"type": "string"},
+          "volume": {"type": "number"},
+          "muted": {"type": "boolean"},
+          "error": {"type": "string"},
+          "updatedAt": {"type": "string", "format": "date-time"}
+        }
+      },
+      "Device": {
+        "type": "object",
+        "properties": {
+          "name": {"type": "string"},
+          "ipAddress": {"type": "string", "description": "Empty until the device is found on the network"},
+          "qualityMax": {"type": "string"},
+          "qualityMin": {"type": "string"},
+          "prefer60fps": {"type": "boolean"},
+          "audioOnly": {"type": "boolean"},
+          "status": {"$ref": "#/components/schemas/DeviceStatus"}
+        }
+      },
+      "DeviceList": {
+        "type": "object",
+        "required": ["devices"],
+        "properties": {
+          "devices": {"type": "array", "items": {"$ref": "#/components/schemas/Device"}}
+        }
+      },
+      "CastRequest": {
+        "type": "object",
+        "required": ["stream", "device"],
+        "properties": {
+          "stream": {"type": "string", "description": "A channel login or a VOD such as videos/123"},
+          "device": {"type": "string", "description": "The name or IP address of a configured Chromecast"}
+        }
+      },
+      "Job": {
+        "type": "object",
+        "properties": {
+          "id": {"type": "string"},
+          "streamer": {"type": "string"},
+          "ipAddress": {"type": "string"},
+          "state": {"type": "string", "enum": ["resolving", "loading", "playing", "failed"]},
+          "error": {"type": "string"},
+          "createdAt": {"type": "string", "format": "date-time"},
+          "updatedAt": {"type": "string", "format": "date-time"}
+        }
+      }
+    }
+  }
+}`
+
+// OpenAPI is the entry point for GET /api/v1/openapi.json, the OpenAPI 3 document describing the API
+func (a *APIEndpoint) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+
+	var spec map[string]interface{}
+	if err := json.Unmarshal([]byte(openAPISpec), &spec); err != nil {
+		fmt.Println("Error parsing the OpenAPI spec: ", err)
+		writeAPIError(w, http.StatusInternalServerError, "Invalid OpenAPI spec")
+		return
+	}
+	spec["servers"] = []map[string]string{{"url": a.apiURL}}
+	writeJSON(w, http.StatusOK, spec)
+}
@@ -0,0 +1,159 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"twitch-caster/cast"
+	"twitch-caster/models"
+)
+
+// Methods a route is tried with to find out which ones it accepts
+var openAPIMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// newTestAPIMux routes the API the same way main.go does
+func newTestAPIMux(t *testing.T, apiURL string) (*http.ServeMux, *APIEndpoint) {
+	config := testConfig(true)
+	config.Settings.APIURL = apiURL
+	apiEndpoint := NewAPIEndpoint(newTestTwitchEndpoint(t, config), apiURL)
+
+	mux := http.NewServeMux()
+	apiEndpoint.Register(mux)
+	return mux, apiEndpoint
+}
+
+type openAPIDocument struct {
+	Servers    []struct{ URL string } `json:"servers"`
+	Paths      map[string]map[string]json.RawMessage
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage
+		}
+	}
+}
+
+func fetchOpenAPI(t *testing.T, mux *http.ServeMux, apiURL string) openAPIDocument {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, apiURL+"/openapi.json", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", recorder.Code, recorder.Body)
+	}
+	var document openAPIDocument
+	decodeTestJSON(t, recorder, &document)
+	return document
+}
+
+func TestOpenAPIServer(t *testing.T) {
+	mux, _ := newTestAPIMux(t, "/api/v2")
+	document := fetchOpenAPI(t, mux, "/api/v2")
+	if len(document.Servers) != 1 || document.Servers[0].URL != "/api/v2" {
+		t.Errorf("servers = %+v", document.Servers)
+	}
+}
+
+// TestOpenAPIPaths checks every path in the spec is routed and accepts exactly the methods the spec lists
+func TestOpenAPIPaths(t *testing.T) {
+	const apiURL = "/api/v1"
+	mux, apiEndpoint := newTestAPIMux(t, apiURL)
+	document := fetchOpenAPI(t, mux, apiURL)
+
+	var paths []string
+	for path := range document.Paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	// Every route Register adds, with the ID of routes ending in a slash as a path parameter
+	var routes []string
+	for route := range apiEndpoint.routes() {
+		if strings.HasSuffix(route, "/") {
+			route += "{id}"
+		}
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	if !reflect.DeepEqual(paths, routes) {
+		t.Errorf("spec has paths %v, the API routes %v", paths, routes)
+	}
+
+	for _, path := range paths {
+		target := apiURL + strings.Replace(path, "{id}", "missing", 1)
+		if _, pattern := mux.Handler(httptest.NewRequest(http.MethodGet, target, nil)); pattern == "" {
+			t.Errorf("%s isn't routed", path)
+			continue
+		}
+
+		for _, method := range openAPIMethods {
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest(method, target, strings.NewReader("{}")))
+			_, documented := document.Paths[path][strings.ToLower(method)]
+			accepted := recorder.Code != http.StatusMethodNotAllowed
+			if documented != accepted {
+				t.Errorf("%s %s documented %v but accepted %v (status %d)", method, path, documented, accepted, recorder.Code)
+			}
+		}
+	}
+}
+
+// jsonFields returns the field names v marshals to
+func jsonFields(t *testing.T, v interface{}) []string {
+	t.Helper()
+	body, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(body, &object); err != nil {
+		t.Fatal(err)
+	}
+	fields := make([]string, 0, len(object))
+	for field := range object {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// TestOpenAPISchemas checks each component schema lists the fields its Go type marshals to.
+// Optional fields are filled in so they aren't left out.
+func TestOpenAPISchemas(t *testing.T) {
+	mux, _ := newTestAPIMux(t, "/api/v1")
+	document := fetchOpenAPI(t, mux, "/api/v1")
+	now := time.Now()
+	tests := []struct {
+		schema string
+		value  interface{}
+	}{
+		{"Error", apiError{apiErrorBody{http.StatusNotFound, "Not found"}}},
+		{"Stream", models.OnlineStreamer{}},
+		{"StreamList", streamsJSONResponse{UpdatedAt: now, Error: "Twitch is down"}},
+		{"DeviceStatus", cast.DeviceStatus{Error: "Offline", UpdatedAt: now}},
+		{"Device", deviceJSON{QualityMin: "360p"}},
+		{"DeviceList", devicesJSONResponse{}},
+		{"CastRequest", castJSONRequest{}},
+		{"Job", cast.Job{Error: "No stream", CreatedAt: now, UpdatedAt: now}},
+	}
+	for _, test := range tests {
+		t.Run(test.schema, func(t *testing.T) {
+			schema, ok := document.Components.Schemas[test.schema]
+			if !ok {
+				t.Fatalf("no %s schema", test.schema)
+			}
+			var properties []string
+			for property := range schema.Properties {
+				properties = append(properties, property)
+			}
+			sort.Strings(properties)
+
+			if fields := jsonFields(t, test.value); !reflect.DeepEqual(fields, properties) {
+				t.Errorf("%T marshals to %v, schema has %v", test.value, fields, properties)
+			}
+		})
+	}
+}
@@ -0,0 +1,275 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+
+	"twitch-caster/cast"
+)
+
+// How often a remote is checked for device status changes to push, tests shorten it
+var remoteStatusInterval = 2 * time.Second
+
+// Remote message types
+const (
+	remoteAck    = "ack"
+	remoteStatus = "status"
+)
+
+// Remote commands
+const (
+	remoteCast   = "cast"
+	remoteStop   = "stop"
+	remotePause  = "pause"
+	remoteResume = "resume"
+	remoteVolume = "volume"
+	remoteMute   = "mute"
+	remoteUnmute = "unmute"
+	remoteSeek   = "seek"
+	remoteSelect = "select"
+)
+
+// remoteCommand is a message from a remote. Device is a name or IP address and can be left out after a select command.
+// Value is required by volume and seek commands.
+type remoteCommand struct {
+	ID     string   `json:"id"`
+	Type   string   `json:"type"`
+	Device string   `json:"device,omitempty"`
+	Stream string   `json:"stream,omitempty"`
+	Value  *float64 `json:"value,omitempty"`
+}
+
+// remoteAckMessage acknowledges a single command by its ID
+type remoteAckMessage struct {
+	Type  string    `json:"type"`
+	ID    string    `json:"id"`
+	OK    bool      `json:"ok"`
+	Error string    `json:"error,omitempty"`
+	Job   *cast.Job `json:"job,omitempty"`
+}
+
+// remoteStatusMessage is pushed when a remote connects and whenever a device's status changes
+type remoteStatusMessage struct {
+	Type    string              `json:"type"`
+	Device  string              `json:"device,omitempty"`
+	Devices []cast.DeviceStatus `json:"devices"`
+}
+
+// RemoteEndpoint is a WebSocket control channel for remote control clients such as phones
+type RemoteEndpoint struct {
+	twitchEndpoint *TwitchEndpoint
+}
+
+// NewRemoteEndpoint creates a new RemoteEndpoint object that controls the devices of twitchEndpoint
+func NewRemoteEndpoint(twitchEndpoint *TwitchEndpoint) *RemoteEndpoint {
+	remoteEndpoint := RemoteEndpoint{}
+	remoteEndpoint.twitchEndpoint = twitchEndpoint
+	return &remoteEndpoint
+}
+
+// ServeHTTP upgrades the request to a WebSocket. Browsers may only connect from pages served by this server.
+func (re *RemoteEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	server := websocket.Server{Handler: re.serve, Handshake: checkRemoteOrigin}
+	server.ServeHTTP(w, r)
+}
+
+// checkRemoteOrigin refuses browsers on other sites, clients that aren't browsers don't send an origin
+func checkRemoteOrigin(config *websocket.Config, r *http.Request) error {
+	origin, err := websocket.Origin(config, r)
+	if err != nil {
+		return err
+	}
+	if origin != nil && origin.Host != r.Host {
+		return errors.New("Origin " + origin.Host + " not allowed")
+	}
+	config.Origin = origin
+	return nil
+}
+
+// remoteConnection is the state of a single connected remote
+type remoteConnection struct {
+	endpoint *RemoteEndpoint
+	conn     *websocket.Conn
+
+	// Held while sending so acknowledgements and status pushes don't interleave
+	sendMutex sync.Mutex
+
+	deviceMutex sync.Mutex
+	device      string
+}
+
+func (re *RemoteEndpoint) serve(conn *websocket.Conn) {
+	defer conn.Close()
+	conn.MaxPayloadBytes = maxAPIRequestSize
+	remote := remoteConnection{endpoint: re, conn: conn}
+
+	done := make(chan struct{})
+	defer close(done)
+	go remote.pushStatus(done)
+
+	for {
+		var command remoteCommand
+		err := websocket.JSON.Receive(conn, &command)
+		switch err.(type) {
+		case nil:
+		case *json.SyntaxError, *json.UnmarshalTypeError:
+			remote.send(remoteAckMessage{Type: remoteAck, Error: "Invalid JSON message"})
+			continue
+		default:
+			if err == websocket.ErrFrameTooLarge {
+				remote.send(remoteAckMessage{Type: remoteAck, Error: "Message too large"})
+				continue
+			}
+			return
+		}
+		remote.send(remote.handle(command))
+	}
+}
+
+// pushStatus sends the device statuses straight away, then again whenever they change, until done is closed
+func (r *remoteConnection) pushStatus(done <-chan struct{}) {
+	ticker := time.NewTicker(remoteStatusInterval)
+	defer ticker.Stop()
+
+	var previous remoteStatusMessage
+	for {
+		status := remoteStatusMessage{Type: remoteStatus, Device: r.selectedDevice(), Devices: r.endpoint.twitchEndpoint.statusPoller.Statuses()}
+		if !sameStatus(status, previous) {
+			if err := r.send(status); err != nil {
+				return
+			}
+			previous = status
+		}
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sameStatus compares two status messages, ignoring when each device was last polled
+func sameStatus(a remoteStatusMessage, b remoteStatusMessage) bool {
+	if a.Device != b.Device || len(a.Devices) != len(b.Devices) {
+		return false
+	}
+	for i := range a.Devices {
+		first, second := a.Devices[i], b.Devices[i]
+		first.UpdatedAt, second.UpdatedAt = time.Time{}, time.Time{}
+		if !reflect.DeepEqual(first, second) {
+			return false
+		}
+	}
+	return true
+}
+
+func (r *remoteConnection) send(message interface{}) error {
+	r.sendMutex.Lock()
+	defer r.sendMutex.Unlock()
+	return websocket.JSON.Send(r.conn, message)
+}
+
+func (r *remoteConnection) selectedDevice() string {
+	r.deviceMutex.Lock()
+	defer r.deviceMutex.Unlock()
+	return r.device
+}
+
+// handle runs a command and returns its acknowledgement
+func (r *remoteConnection) handle(command remoteCommand) remoteAckMessage {
+	ack := remoteAckMessage{Type: remoteAck, ID: command.ID}
+
+	device := command.Device
+	if device == "" {
+		device = r.selectedDevice()
+	}
+	if device == "" {
+		ack.Error = "No device given, send a select command or include a device"
+		return ack
+	}
+
+	twitchEndpoint := r.endpoint.twitchEndpoint
+	chromecast, ok := twitchEndpoint.findDevice(device)
+	if !ok {
+		ack.Error = "Unknown Chromecast device " + device
+		return ack
+	}
+	if command.Type == remoteSelect {
+		r.deviceMutex.Lock()
+		r.device = chromecast.Name
+		r.deviceMutex.Unlock()
+		ack.OK = true
+		return ack
+	}
+	if command.Type == remoteCast {
+		stream := strings.TrimSpace(command.Stream)
+		if stream == "" {
+			ack.Error = "No stream given"
+			return ack
+		}
+		job, err := twitchEndpoint.StartCast(stream, chromecast)
+		if err != nil {
+			ack.Error = err.Error()
+			return ack
+		}
+		ack.Job = &job
+		ack.OK = true
+		return ack
+	}
+
+	if (command.Type == remoteVolume || command.Type == remoteSeek) && command.Value == nil {
+		ack.Error = "No value given"
+		return ack
+	}
+
+	ipAddress := twitchEndpoint.registry.Address(chromecast)
+	if ipAddress == "" {
+		ack.Error = "Chromecast device " + chromecast.Name + " has not been found on the network"
+		return ack
+	}
+
+	controller := twitchEndpoint.castController
+	var err error
+	switch command.Type {
+	case remoteStop:
+		err = controller.Stop(ipAddress)
+	case remotePause:
+		err = controller.Pause(ipAddress)
+	case remoteResume:
+		err = controller.Resume(ipAddress)
+	case remoteVolume:
+		if *command.Value < 0 || *command.Value > 1 {
+			ack.Error = "Volume must be between 0 and 1"
+			return ack
+		}
+		err = controller.SetVolume(ipAddress, float32(*command.Value))
+	case remoteMute:
+		err = controller.SetMuted(ipAddress, true)
+	case remoteUnmute:
+		err = controller.SetMuted(ipAddress, false)
+	case remoteSeek:
+		err = controller.Seek(ipAddress, int(*command.Value))
+	default:
+		ack.Error = "Unknown command " + command.Type
+		return ack
+	}
+
+	if err != nil {
+		fmt.Println("Error controlling Chromecast: ", err)
+		ack.Error = err.Error()
+		return ack
+	}
+	// Poll now so every remote sees the result without waiting for the next scheduled poll
+	go twitchEndpoint.statusPoller.Poll()
+	ack.OK = true
+	return ack
+}
@@ -0,0 +1,219 @@
+package endpoints
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func value(v float64) *float64 {
+	return &v
+}
+
+func newTestRemote(t *testing.T) *remoteConnection {
+	return &remoteConnection{endpoint: NewRemoteEndpoint(newTestTwitchEndpoint(t, testConfig(true)))}
+}
+
+func TestRemoteCast(t *testing.T) {
+	tests := []struct {
+		name    string
+		command remoteCommand
+		error   string
+	}{
+		{"by name", remoteCommand{ID: "1", Type: remoteCast, Device: "living room", Stream: " somestreamer "}, ""},
+		{"by address", remoteCommand{ID: "2", Type: remoteCast, Device: "192.168.1.1", Stream: "videos/123"}, ""},
+		{"no stream", remoteCommand{ID: "3", Type: remoteCast, Device: "Living Room", Stream: " "}, "No stream given"},
+		{"no device", remoteCommand{ID: "4", Type: remoteCast, Stream: "somestreamer"}, "No device given"},
+		{"unknown device", remoteCommand{ID: "5", Type: remoteCast, Device: "Bedroom", Stream: "somestreamer"}, "Unknown Chromecast device Bedroom"},
+		{"not discovered", remoteCommand{ID: "6", Type: remoteCast, Device: "Kitchen", Stream: "somestreamer"}, "Chromecast device Kitchen has not been found on the network"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ack := newTestRemote(t).handle(test.command)
+			if ack.Type != remoteAck || ack.ID != test.command.ID {
+				t.Errorf("ack = %+v", ack)
+			}
+			if test.error != "" {
+				if ack.OK || ack.Job != nil || !strings.Contains(ack.Error, test.error) {
+					t.Errorf("ack = %+v, want error %q", ack, test.error)
+				}
+				return
+			}
+			if !ack.OK || ack.Error != "" || ack.Job == nil {
+				t.Fatalf("ack = %+v", ack)
+			}
+			if ack.Job.Streamer != strings.TrimSpace(test.command.Stream) || ack.Job.IPAddress != "192.168.1.1" {
+				t.Errorf("job = %+v", ack.Job)
+			}
+		})
+	}
+}
+
+func TestRemoteSelectThenCast(t *testing.T) {
+	remote := newTestRemote(t)
+	if ack := remote.handle(remoteCommand{ID: "1", Type: remoteSelect, Device: "living room"}); !ack.OK {
+		t.Fatalf("select ack = %+v", ack)
+	}
+	if device := remote.selectedDevice(); device != "Living Room" {
+		t.Errorf("selected device = %q", device)
+	}
+
+	ack := remote.handle(remoteCommand{ID: "2", Type: remoteCast, Stream: "somestreamer"})
+	if !ack.OK || ack.Job == nil || ack.Job.IPAddress != "192.168.1.1" {
+		t.Errorf("cast ack = %+v", ack)
+	}
+}
+
+func TestRemoteControlErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		command remoteCommand
+		error   string
+	}{
+		{"not discovered", remoteCommand{Type: remoteStop, Device: "Kitchen"}, "Chromecast device Kitchen has not been found on the network"},
+		{"volume too high", remoteCommand{Type: remoteVolume, Device: "Living Room", Value: value(1.5)}, "Volume must be between 0 and 1"},
+		{"volume negative", remoteCommand{Type: remoteVolume, Device: "Living Room", Value: value(-0.1)}, "Volume must be between 0 and 1"},
+		{"volume without value", remoteCommand{Type: remoteVolume, Device: "Living Room"}, "No value given"},
+		{"seek without value", remoteCommand{Type: remoteSeek, Device: "Living Room"}, "No value given"},
+		{"unknown command", remoteCommand{Type: "rewind", Device: "Living Room"}, "Unknown command rewind"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ack := newTestRemote(t).handle(test.command)
+			if ack.OK || ack.Error != test.error {
+				t.Errorf("ack = %+v, want error %q", ack, test.error)
+			}
+		})
+	}
+}
+
+// dialTestRemote serves a RemoteEndpoint over a real WebSocket and connects to it from the same origin
+func dialTestRemote(t *testing.T) *websocket.Conn {
+	interval := remoteStatusInterval
+	remoteStatusInterval = 10 * time.Millisecond
+	t.Cleanup(func() { remoteStatusInterval = interval })
+
+	server := httptest.NewServer(NewRemoteEndpoint(newTestTwitchEndpoint(t, testConfig(true))))
+	t.Cleanup(server.Close)
+
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http"), "", server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+// receiveAck skips status pushes until the next acknowledgement arrives
+func receiveAck(t *testing.T, conn *websocket.Conn) remoteAckMessage {
+	t.Helper()
+	for {
+		var message json.RawMessage
+		if err := websocket.JSON.Receive(conn, &message); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(message), `"type":"`+remoteAck+`"`) {
+			continue
+		}
+		var ack remoteAckMessage
+		if err := json.Unmarshal(message, &ack); err != nil {
+			t.Fatal(err)
+		}
+		return ack
+	}
+}
+
+// receiveStatus skips messages until a status push for device arrives
+func receiveStatus(t *testing.T, conn *websocket.Conn, device string) remoteStatusMessage {
+	t.Helper()
+	for {
+		var status remoteStatusMessage
+		if err := websocket.JSON.Receive(conn, &status); err != nil {
+			t.Fatal(err)
+		}
+		if status.Type == remoteStatus && status.Device == device {
+			return status
+		}
+	}
+}
+
+func TestRemoteWebSocket(t *testing.T) {
+	conn := dialTestRemote(t)
+
+	// The statuses are pushed as soon as a remote connects
+	status := receiveStatus(t, conn, "")
+	if len(status.Devices) != 2 || status.Devices[0].Name != "Living Room" || status.Devices[0].IPAddress != "192.168.1.1" || status.Devices[1].Name != "Kitchen" {
+		t.Errorf("status = %+v", status)
+	}
+
+	if err := websocket.JSON.Send(conn, remoteCommand{ID: "1", Type: remoteSelect, Device: "living room"}); err != nil {
+		t.Fatal(err)
+	}
+	if ack := receiveAck(t, conn); !ack.OK || ack.ID != "1" {
+		t.Errorf("select ack = %+v", ack)
+	}
+	// Selecting a device changes the status, so it is pushed again
+	receiveStatus(t, conn, "Living Room")
+
+	if err := websocket.JSON.Send(conn, remoteCommand{ID: "2", Type: remoteCast, Stream: "somestreamer"}); err != nil {
+		t.Fatal(err)
+	}
+	ack := receiveAck(t, conn)
+	if !ack.OK || ack.ID != "2" || ack.Job == nil || ack.Job.ID == "" || ack.Job.Streamer != "somestreamer" || ack.Job.IPAddress != "192.168.1.1" {
+		t.Errorf("cast ack = %+v", ack)
+	}
+
+	if err := websocket.JSON.Send(conn, remoteCommand{ID: "3", Type: remoteVolume, Value: value(1.5)}); err != nil {
+		t.Fatal(err)
+	}
+	if ack := receiveAck(t, conn); ack.OK || ack.ID != "3" || ack.Error != "Volume must be between 0 and 1" {
+		t.Errorf("volume ack = %+v", ack)
+	}
+}
+
+func TestRemoteWebSocketInvalidMessages(t *testing.T) {
+	conn := dialTestRemote(t)
+	messages := []struct {
+		name    string
+		message string
+		error   string
+	}{
+		{"invalid json", `{"id":`, "Invalid JSON message"},
+		{"wrong type", `{"id":"1","type":"volume","value":"loud"}`, "Invalid JSON message"},
+		{"too large", `{"id":"` + strings.Repeat("a", maxAPIRequestSize) + `"}`, "Message too large"},
+		// Without a value the volume would otherwise be set to 0
+		{"volume without value", `{"id":"1","type":"volume","device":"Living Room"}`, "No value given"},
+	}
+	for _, test := range messages {
+		if err := websocket.Message.Send(conn, test.message); err != nil {
+			t.Fatal(err)
+		}
+		if ack := receiveAck(t, conn); ack.OK || ack.Error != test.error {
+			t.Errorf("%s: ack = %+v, want error %q", test.name, ack, test.error)
+		}
+	}
+
+	// The connection stays usable after a bad message
+	if err := websocket.JSON.Send(conn, remoteCommand{ID: "2", Type: remoteSelect, Device: "Kitchen"}); err != nil {
+		t.Fatal(err)
+	}
+	if ack := receiveAck(t, conn); !ack.OK || ack.ID != "2" {
+		t.Errorf("select ack = %+v", ack)
+	}
+}
+
+func TestRemoteWebSocketOtherOrigin(t *testing.T) {
+	server := httptest.NewServer(NewRemoteEndpoint(newTestTwitchEndpoint(t, testConfig(true))))
+	defer server.Close()
+
+	conn, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http"), "", "http://evil.example.com")
+	if err == nil {
+		conn.Close()
+		t.Fatal("a remote on another site connected")
+	}
+}
@@ -0,0 +1,106 @@
+package endpoints
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+// Long enough that a versioned asset is only fetched again when its content changes
+const immutableCacheControl = "public, max-age=31536000, immutable"
+
+// StaticEndpoint serves the embedded static assets, preferring any file of the same name in an override directory
+type StaticEndpoint struct {
+	files  fs.FS
+	prefix string
+	hashes map[string]string
+}
+
+// NewStaticEndpoint creates a StaticEndpoint serving files under prefix, overrideDir may be empty
+func NewStaticEndpoint(files fs.FS, overrideDir string, prefix string) *StaticEndpoint {
+	staticEndpoint := StaticEndpoint{}
+	staticEndpoint.files = files
+	staticEndpoint.prefix = prefix
+	staticEndpoint.hashes = make(map[string]string)
+
+	// Both directories are walked because the overlay only lists the override when asked for a directory
+	directories := []fs.FS{files}
+	if overrideDir != "" {
+		staticEndpoint.files = overlayFS{os.DirFS(overrideDir), files}
+		directories = append(directories, os.DirFS(overrideDir))
+	}
+	for _, directory := range directories {
+		fs.WalkDir(directory, ".", func(name string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			if data, err := fs.ReadFile(staticEndpoint.files, name); err == nil {
+				staticEndpoint.hashes[name] = contentHash(data)
+			}
+			return nil
+		})
+	}
+	return &staticEndpoint
+}
+
+// URL returns the address of an asset, versioned by its content so browsers can cache it indefinitely
+func (s *StaticEndpoint) URL(name string) string {
+	hash, ok := s.hashes[name]
+	if !ok {
+		return s.prefix + name
+	}
+	return s.prefix + name + "?v=" + hash
+}
+
+// ServeHTTP serves an asset, only allowing it to be cached indefinitely when the version in the URL matches its content
+func (s *StaticEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(r.URL.Path, s.prefix)), "/")
+	data, err := fs.ReadFile(s.files, name)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	hash := contentHash(data)
+	w.Header().Set("ETag", "\""+hash+"\"")
+	if r.URL.Query().Get("v") == hash {
+		w.Header().Set("Cache-Control", immutableCacheControl)
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+
+	if match := r.Header.Get("If-None-Match"); match != "" && match == w.Header().Get("ETag") {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if _, err := w.Write(data); err != nil {
+		fmt.Println("Error serving static file: ", err)
+	}
+}
+
+func contentHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+// overlayFS opens files from override when it has them and from base otherwise
+type overlayFS struct {
+	override fs.FS
+	base     fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	if file, err := o.override.Open(name); err == nil {
+		return file, nil
+	}
+	return o.base.Open(name)
+}
@@ -0,0 +1,120 @@
+package endpoints
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// serveStatic requests target from staticEndpoint, with an If-None-Match header unless etag is empty
+func serveStatic(staticEndpoint *StaticEndpoint, target string, etag string) *httptest.ResponseRecorder {
+	request := httptest.NewRequest(http.MethodGet, target, nil)
+	if etag != "" {
+		request.Header.Set("If-None-Match", etag)
+	}
+	recorder := httptest.NewRecorder()
+	staticEndpoint.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestStaticCacheControl(t *testing.T) {
+	staticEndpoint := testStatic()
+	versioned := staticEndpoint.URL("style.css")
+	if versioned != "/static/style.css?v="+contentHash([]byte("body {}")) {
+		t.Fatalf("URL() = %q", versioned)
+	}
+
+	tests := []struct {
+		name         string
+		target       string
+		cacheControl string
+	}{
+		{"unversioned", "/static/style.css", "no-cache"},
+		{"versioned", versioned, immutableCacheControl},
+		// An old page may ask for a version that has since changed, it mustn't be cached as that version
+		{"stale version", "/static/style.css?v=0123456789ab", "no-cache"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := serveStatic(staticEndpoint, test.target, "")
+			if recorder.Code != http.StatusOK || recorder.Body.String() != "body {}" {
+				t.Fatalf("status = %d, body %q", recorder.Code, recorder.Body)
+			}
+			if cacheControl := recorder.Header().Get("Cache-Control"); cacheControl != test.cacheControl {
+				t.Errorf("Cache-Control = %q, want %q", cacheControl, test.cacheControl)
+			}
+			if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/css") {
+				t.Errorf("Content-Type = %q", contentType)
+			}
+			if etag := recorder.Header().Get("ETag"); etag != `"`+contentHash([]byte("body {}"))+`"` {
+				t.Errorf("ETag = %q", etag)
+			}
+		})
+	}
+}
+
+func TestStaticNotModified(t *testing.T) {
+	staticEndpoint := testStatic()
+	etag := serveStatic(staticEndpoint, "/static/style.css", "").Header().Get("ETag")
+
+	recorder := serveStatic(staticEndpoint, "/static/style.css", etag)
+	if recorder.Code != http.StatusNotModified || recorder.Body.Len() != 0 {
+		t.Errorf("status = %d, body %q, want 304 without a body", recorder.Code, recorder.Body)
+	}
+	if recorder.Header().Get("ETag") != etag || recorder.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("headers = %v", recorder.Header())
+	}
+
+	if recorder := serveStatic(staticEndpoint, "/static/style.css", `"0123456789ab"`); recorder.Code != http.StatusOK {
+		t.Errorf("status with a different ETag = %d, want 200", recorder.Code)
+	}
+}
+
+func TestStaticNotFound(t *testing.T) {
+	staticEndpoint := testStatic()
+	for _, target := range []string{"/static/missing.css", "/static/../go.mod", "/static/"} {
+		if recorder := serveStatic(staticEndpoint, target, ""); recorder.Code != http.StatusNotFound {
+			t.Errorf("%s status = %d, want 404", target, recorder.Code)
+		}
+	}
+	if url := staticEndpoint.URL("missing.css"); url != "/static/missing.css" {
+		t.Errorf("URL() of a missing file = %q", url)
+	}
+}
+
+func TestStaticOverrideDir(t *testing.T) {
+	dir := t.TempDir()
+	ioutil.WriteFile(filepath.Join(dir, "style.css"), []byte("body { color: red }"), 0644)
+	ioutil.WriteFile(filepath.Join(dir, "theme.css"), []byte("h1 {}"), 0644)
+	staticEndpoint := NewStaticEndpoint(testStatic().files, dir, "/static/")
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		// The override replaces the built in file, and is versioned by its own content
+		{"style.css", "body { color: red }"},
+		// Files that aren't overridden are still served
+		{"favicon.ico", "icon"},
+		// As are files only in the override directory
+		{"theme.css", "h1 {}"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			url := staticEndpoint.URL(test.name)
+			if url != "/static/"+test.name+"?v="+contentHash([]byte(test.body)) {
+				t.Errorf("URL() = %q", url)
+			}
+			recorder := serveStatic(staticEndpoint, url, "")
+			if recorder.Code != http.StatusOK || recorder.Body.String() != test.body {
+				t.Errorf("status = %d, body %q, want %q", recorder.Code, recorder.Body, test.body)
+			}
+			if cacheControl := recorder.Header().Get("Cache-Control"); cacheControl != immutableCacheControl {
+				t.Errorf("Cache-Control = %q", cacheControl)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package endpoints
+
+import (
+	"embed"
+	"html/template"
+	"strconv"
+
+	"twitch-caster/cast"
+	"twitch-caster/models"
+)
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+var templates = template.Must(template.New("").Funcs(template.FuncMap{
+	"describeStatus": describeStatus,
+	"formatCount":    formatCount,
+}).ParseFS(templateFiles, "templates/*.html"))
+
+// channelListPage is the data the channel list template is rendered with
+type channelListPage struct {
+	Static       *StaticEndpoint
+	CastURL      string
+	JobsURL      string
+	ControlURL   string
+	EventsURL    string
+	Devices      []deviceOption
+	Statuses     []cast.DeviceStatus
+	UpdatedAgo   string
+	RefreshError string
+	Streamers    []models.OnlineStreamer
+}
+
+// BlankStreamer is rendered as the template the page fills in for streams that go live after it loads
+func (channelListPage) BlankStreamer() models.OnlineStreamer {
+	return models.OnlineStreamer{}
+}
+
+// deviceOption is a Chromecast in the device picker, IPAddress is empty while it is being searched for
+type deviceOption struct {
+	Name      string
+	IPAddress string
+}
+
+// formatCount formats a count with thousands separators, like 12,345
+func formatCount(count int) string {
+	digits := strconv.Itoa(count)
+	sign := ""
+	if count < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return sign + digits
+}
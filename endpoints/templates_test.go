@@ -0,0 +1,128 @@
+package endpoints
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"twitch-caster/cast"
+	"twitch-caster/models"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testStatic serves fixed assets so the golden files don't change with the real ones
+func testStatic() *StaticEndpoint {
+	files := fstest.MapFS{
+		"style.css":       {Data: []byte("body {}")},
+		"favicon.ico":     {Data: []byte("icon")},
+		"twitch-logo.png": {Data: []byte("logo")},
+	}
+	return NewStaticEndpoint(files, "", "/static/")
+}
+
+// hostilePage has quotes and markup in everything that comes from Twitch or the configuration
+func hostilePage() channelListPage {
+	return channelListPage{
+		Static:     testStatic(),
+		CastURL:    `/gui/cast/"+alert('cast')+"/`,
+		JobsURL:    "/gui/jobs/",
+		ControlURL: "/gui/control/",
+		EventsURL:  "/gui/events",
+		Devices: []deviceOption{
+			{`Living "Room" <script>alert('device')</script>`, "192.168.1.1"},
+			{"Kitchen", ""},
+		},
+		Statuses: []cast.DeviceStatus{
+			{Name: `Living "Room" <script>alert('device')</script>`, Online: true, AppRunning: true, PlayerState: "PLAYING", Streamer: `O'Brien"<script>`},
+			{Name: "Kitchen", Error: "Not found"},
+		},
+		UpdatedAgo:   "20s",
+		RefreshError: `Twitch said <b>"no"</b>`,
+		Streamers: []models.OnlineStreamer{{
+			UserID:          "2",
+			Login:           `o'brien"><script>alert('login')</script>`,
+			Name:            `O'Brien "<script>alert('name')</script>"`,
+			Game:            "Tom & Jerry's",
+			ProfileImageURL: `https://example.com/2.png" onerror="alert('image')`,
+			Title:           `</h3><script>alert('title')</script>`,
+			ThumbnailURL:    "javascript:alert('thumbnail')",
+			ViewerCount:     12345,
+		}},
+	}
+}
+
+// renderGolden renders the channel list and compares it with testdata/name.golden, rewriting it with -update
+func renderGolden(t *testing.T, name string, page channelListPage) string {
+	t.Helper()
+	var buffer bytes.Buffer
+	if err := templates.ExecuteTemplate(&buffer, "channel_list.html", page); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := ioutil.WriteFile(golden, buffer.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := ioutil.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buffer.Bytes(), want) {
+		t.Errorf("channel list differs from %s, rerun with -update if the change is intended", golden)
+	}
+	return buffer.String()
+}
+
+func TestChannelListGolden(t *testing.T) {
+	page := renderGolden(t, "channel_list", hostilePage())
+
+	if strings.Contains(page, "<script>alert") || strings.Contains(page, `onerror="`) {
+		t.Error("page contains unescaped markup from the streamer or configuration")
+	}
+	escaped := []string{
+		`data-login="o&#39;brien&#34;&gt;&lt;script&gt;alert(&#39;login&#39;)&lt;/script&gt;"`,
+		`<h3 class="streamTitle">&lt;/h3&gt;&lt;script&gt;alert(&#39;title&#39;)&lt;/script&gt;</h3>`,
+		`<img src="#ZgotmplZ" class="thumbnailImage">`,
+		`<div class="viewerCount">12,345 viewers</div>`,
+	}
+	for _, want := range escaped {
+		if !strings.Contains(page, want) {
+			t.Errorf("page doesn't contain %s", want)
+		}
+	}
+
+	// How quotes are escaped in scripts depends on the Go release, so decode the literal rather than match it
+	var castURL string
+	for _, line := range strings.Split(page, "\n") {
+		if literal := strings.TrimPrefix(strings.TrimSpace(line), "const castURL = "); literal != strings.TrimSpace(line) {
+			if err := json.Unmarshal([]byte(literal), &castURL); err != nil {
+				t.Errorf("castURL %s isn't a single string literal: %v", literal, err)
+			}
+		}
+	}
+	if castURL != hostilePage().CastURL {
+		t.Errorf("castURL = %q, want %q", castURL, hostilePage().CastURL)
+	}
+}
+
+func TestChannelListEmptyGolden(t *testing.T) {
+	page := channelListPage{
+		Static:     testStatic(),
+		CastURL:    "/gui/cast/",
+		JobsURL:    "/gui/jobs/",
+		ControlURL: "/gui/control/",
+		EventsURL:  "/gui/events",
+		Devices:    []deviceOption{{"Kitchen", ""}},
+		Statuses:   []cast.DeviceStatus{{Name: "Kitchen"}},
+		UpdatedAgo: "3m",
+	}
+	renderGolden(t, "channel_list_empty", page)
+}
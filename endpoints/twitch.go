@@ -1,202 +1,351 @@
 package endpoints
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
-	"os/exec"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"twitch-caster/cast"
 	"twitch-caster/models"
 	"twitch-caster/services"
+	"twitch-caster/streams"
 )
 
-// Response object when quality is not specified
-type streamLinkFullResponse struct {
-	Streams map[string]streamLinkStreamInfo `json:"streams"`
-	Plugin  string                          `json:"plugin"`
-}
-
-type streamLinkStreamInfo struct {
-	Type    string `json:"type"`
-	URL     string `json:"url"`
-	Headers struct {
-		UserAgent      string `json:"User-Agent"`
-		AcceptEncoding string `json:"Accept-Encoding"`
-		Accept         string `json:"Accept"`
-		Connection     string `json:"Connection"`
-		ClientID       string `json:"Client-ID"`
-	} `json:"headers"`
+type castJSONResponse struct {
+	Success bool   `json:"success"`
+	JobID   string `json:"jobId,omitempty"`
 }
 
-type castJSONResponse struct {
-	Success bool `json:"success"`
+// CastController loads streams on Chromecasts and controls their playback, a cast.Controller outside of tests
+type CastController interface {
+	Load(url string, ipAddress string, streamer string) error
+	Pause(ipAddress string) error
+	Resume(ipAddress string) error
+	Stop(ipAddress string) error
+	Seek(ipAddress string, seconds int) error
+	SetVolume(ipAddress string, level float32) error
+	SetMuted(ipAddress string, muted bool) error
 }
 
 // TwitchEndpoint contains the endpoints for handling casting and listing the main GUI
 type TwitchEndpoint struct {
-	chromecasts   []models.Chromecast
-	twitchService *services.TwitchService
+	chromecasts    []models.Chromecast
+	streamPoller   *services.StreamPoller
+	castController CastController
+	registry       *cast.Registry
+	statusPoller   *cast.StatusPoller
+	jobTracker     *cast.JobTracker
+	streamResolver streams.StreamResolver
+	loginURL       string
+	castURL        string
+	jobsURL        string
+	controlURL     string
+	eventsURL      string
+	static         *StaticEndpoint
 }
 
 // NewTwitchEndpoint creates a new TwitchEndpoint object
-func NewTwitchEndpoint(config models.Configuration) *TwitchEndpoint {
+func NewTwitchEndpoint(config models.Configuration, streamPoller *services.StreamPoller, streamResolver streams.StreamResolver, castController CastController, registry *cast.Registry, statusPoller *cast.StatusPoller, static *StaticEndpoint) *TwitchEndpoint {
 	twitchEndpoint := TwitchEndpoint{}
 	twitchEndpoint.chromecasts = config.Chromecasts
-	twitchEndpoint.twitchService = services.NewTwitchService(config.Settings)
+	twitchEndpoint.streamPoller = streamPoller
+	twitchEndpoint.loginURL = config.Settings.LoginURL
+	twitchEndpoint.castURL = config.Settings.CastURL
+	twitchEndpoint.jobsURL = config.Settings.JobsURL
+	twitchEndpoint.controlURL = config.Settings.ControlURL
+	twitchEndpoint.eventsURL = config.Settings.EventsURL
+	twitchEndpoint.castController = castController
+	twitchEndpoint.registry = registry
+	twitchEndpoint.statusPoller = statusPoller
+	twitchEndpoint.jobTracker = cast.NewJobTracker()
+	twitchEndpoint.streamResolver = streamResolver
+	twitchEndpoint.static = static
 	return &twitchEndpoint
 }
 
-// CastTwitch is the entry point for a cast twitch HTTP request
+// CastTwitch is the entry point for a cast twitch HTTP request, e.g. POST /gui/cast/<stream>/<ip>
 func (t *TwitchEndpoint) CastTwitch(w http.ResponseWriter, r *http.Request) {
-	var pathParams = strings.Split(r.URL.Path, "/")
+	if !allowPost(w, r) {
+		return
+	}
+
+	// The stream ID may be an escaped VOD path such as videos%2F123, so split before unescaping
+	var pathParams = strings.Split(r.URL.EscapedPath(), "/")
 	var ipAddress = pathParams[len(pathParams)-1]
-	var streamID = pathParams[len(pathParams)-2]
+	streamID, err := url.PathUnescape(pathParams[len(pathParams)-2])
 
-	if streamID == "" {
+	if err != nil || streamID == "" {
+		w.WriteHeader(http.StatusBadRequest)
 		fmt.Fprintf(w, "Invalid stream ID")
 		return
 	}
 
-	var quality string
-	for _, chromecast := range t.chromecasts {
-		if chromecast.IPAddress == ipAddress {
-			quality = chromecast.QualityMax
-		}
-	}
-
-	if quality == "" {
+	chromecast, ok := t.findChromecast(ipAddress)
+	if !ok || chromecast.QualityMax == "" {
 		fmt.Println("Error: Could not determine quality setting for the selected Chromecast device")
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
+	job := t.startCast(streamID, chromecast, ipAddress)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(castJSONResponse{true, job.ID})
+}
+
+// StartCast casts a stream to a configured Chromecast in the background, failing if the device hasn't been found yet
+func (t *TwitchEndpoint) StartCast(streamID string, chromecast models.Chromecast) (cast.Job, error) {
+	ipAddress := t.registry.Address(chromecast)
+	if ipAddress == "" {
+		return cast.Job{}, errors.New("Chromecast device " + chromecast.Name + " has not been found on the network")
+	}
+	return t.startCast(streamID, chromecast, ipAddress), nil
+}
+
+// startCast creates a job that resolves and casts the stream in the background
+func (t *TwitchEndpoint) startCast(streamID string, chromecast models.Chromecast, ipAddress string) cast.Job {
+	job := t.jobTracker.Create(streamID, ipAddress)
 
 	go func() {
-		streamURL, err := t.fetchStream(streamID, quality)
+		streamURL, err := t.fetchStream(streamID, chromecast)
 		if err != nil {
 			fmt.Println("Error fetching stream: ", err)
+			t.jobTracker.Fail(job.ID, err)
 			return
 		}
 
-		err = cast.URL(streamURL, ipAddress)
+		t.jobTracker.SetState(job.ID, cast.JobLoading)
+		err = t.castController.Load(streamURL, ipAddress, streamID)
 		if err != nil {
 			fmt.Println("Error casting stream: ", err)
+			t.jobTracker.Fail(job.ID, err)
 			return
 		}
+		t.jobTracker.SetState(job.ID, cast.JobPlaying)
 	}()
+	return job
+}
+
+// CastJob returns a job started by StartCast by its ID
+func (t *TwitchEndpoint) CastJob(id string) (cast.Job, bool) {
+	return t.jobTracker.Get(id)
+}
+
+// CastJobStatus is the entry point for an HTTP request for the state of a cast job, e.g. /gui/jobs/<id>
+func (t *TwitchEndpoint) CastJobStatus(w http.ResponseWriter, r *http.Request) {
+	var pathParams = strings.Split(r.URL.Path, "/")
+	var jobID = pathParams[len(pathParams)-1]
+
+	job, ok := t.jobTracker.Get(jobID)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "Unknown cast job")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
 }
 
-func (t *TwitchEndpoint) fetchStream(streamID string, quality string) (string, error) {
-	streamLinkCmd := exec.Command("streamlink", "twitch.tv/"+streamID, "--http-header=Client-ID=jzkbprff40iqj646a697cyrvl0zt2m6", "--player-passthrough=http,hls,rtmp", "-j")
-	output, streamLinkError := streamLinkCmd.Output()
+// ControlCast is the entry point for controlling playback on a Chromecast, e.g. POST /gui/control/pause/<ip>?value=
+func (t *TwitchEndpoint) ControlCast(w http.ResponseWriter, r *http.Request) {
+	var pathParams = strings.Split(r.URL.Path, "/")
+	var ipAddress = pathParams[len(pathParams)-1]
+	var action = pathParams[len(pathParams)-2]
+	var value = r.URL.Query().Get("value")
 
-	if streamLinkError != nil {
-		fmt.Printf("Streamlink output: %s\n", output)
-		return "", streamLinkError
+	if !allowPost(w, r) {
+		return
 	}
 
-	var streamLinkResponse streamLinkFullResponse
-	jsonError := json.Unmarshal(output, &streamLinkResponse)
-	if jsonError != nil {
-		return "", jsonError
+	if _, ok := t.findChromecast(ipAddress); !ok {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "Unknown Chromecast device")
+		return
 	}
 
-	var stream streamLinkStreamInfo
-	var ok bool
-	stream, ok = streamLinkResponse.Streams[quality]
-	if !ok {
-		// Couldn't find the requested quality - falling back
-		stream, ok = streamLinkResponse.Streams["480p"]
-		if !ok {
-			fmt.Println("Using worst quality stream")
-			stream, ok = streamLinkResponse.Streams["worst"]
-			if !ok {
-				return "", errors.New("Could not find a lower quality stream")
-			}
+	var err error
+	switch action {
+	case "pause":
+		err = t.castController.Pause(ipAddress)
+	case "resume":
+		err = t.castController.Resume(ipAddress)
+	case "stop":
+		err = t.castController.Stop(ipAddress)
+	case "mute":
+		err = t.castController.SetMuted(ipAddress, true)
+	case "unmute":
+		err = t.castController.SetMuted(ipAddress, false)
+	case "seek":
+		seconds, parseError := strconv.Atoi(value)
+		if parseError != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Invalid seek value")
+			return
+		}
+		err = t.castController.Seek(ipAddress, seconds)
+	case "volume":
+		level, parseError := strconv.ParseFloat(value, 32)
+		if parseError != nil || level < 0 || level > 1 {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, "Invalid volume value")
+			return
 		}
+		err = t.castController.SetVolume(ipAddress, float32(level))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "Unknown control action")
+		return
 	}
-	return stream.URL, nil
+
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		fmt.Println("Error controlling Chromecast: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	json.NewEncoder(w).Encode(castJSONResponse{Success: err == nil})
+}
+
+// allowPost refuses anything but a POST. Casts and controls change what is playing,
+// so a link or image on another site must not be able to trigger them.
+func allowPost(w http.ResponseWriter, r *http.Request) bool {
+	if r.Method == http.MethodPost {
+		return true
+	}
+	w.Header().Set("Allow", http.MethodPost)
+	w.WriteHeader(http.StatusMethodNotAllowed)
+	return false
+}
+
+// DeviceStatus is the entry point for an HTTP request for the status of every Chromecast
+func (t *TwitchEndpoint) DeviceStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(t.statusPoller.Statuses())
+}
+
+// findDevice finds a configured Chromecast by name, ignoring case, or by address
+func (t *TwitchEndpoint) findDevice(device string) (models.Chromecast, bool) {
+	for _, chromecast := range t.chromecasts {
+		if strings.EqualFold(chromecast.Name, device) {
+			return chromecast, true
+		}
+	}
+	return t.findChromecast(device)
+}
+
+// findChromecast finds the configured Chromecast whose configured or discovered address is ipAddress
+func (t *TwitchEndpoint) findChromecast(ipAddress string) (models.Chromecast, bool) {
+	if ipAddress == "" {
+		return models.Chromecast{}, false
+	}
+	for _, chromecast := range t.chromecasts {
+		if t.registry.Address(chromecast) == ipAddress {
+			return chromecast, true
+		}
+	}
+	return models.Chromecast{}, false
+}
+
+func (t *TwitchEndpoint) fetchStream(streamID string, chromecast models.Chromecast) (string, error) {
+	options := streams.QualityOptions{
+		Prefer60FPS: chromecast.Prefer60FPS,
+		AudioOnly:   chromecast.AudioOnly,
+		Minimum:     chromecast.QualityMin,
+	}
+	request, err := streams.ParseTarget(streamID, chromecast.QualityMax, options)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := t.streamResolver.Resolve(request)
+	if err != nil {
+		return "", err
+	}
+	return result.URL, nil
 }
 
 // TwitchChannelList is the entry point for an HTTP channel list request
 func (t *TwitchEndpoint) TwitchChannelList(w http.ResponseWriter, r *http.Request) {
-	twitchFollowsResponse, error := t.twitchService.FetchTwitchFollows()
-	if error != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Println(error)
+	snapshot := t.streamPoller.Snapshot()
+	// Refresh now rather than wait for the poller before the first success, or straight after logging in
+	if snapshot.UpdatedAt.IsZero() || snapshot.Err == services.ErrNotLoggedIn {
+		snapshot = t.streamPoller.Poll(r.Context())
+	}
+	if snapshot.Err == services.ErrNotLoggedIn {
+		http.Redirect(w, r, t.loginURL, http.StatusFound)
 		return
 	}
-
-	onlineUsersResponse, error := t.twitchService.FetchTwitchStreamersStatus(twitchFollowsResponse)
-	if error != nil {
+	if snapshot.UpdatedAt.IsZero() {
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Println(error)
+		fmt.Println(snapshot.Err)
 		return
 	}
+	page := channelListPage{
+		Static:     t.static,
+		CastURL:    t.castURL,
+		JobsURL:    t.jobsURL,
+		ControlURL: t.controlURL,
+		EventsURL:  t.eventsURL,
+		Statuses:   t.statusPoller.Statuses(),
+		UpdatedAgo: describeAge(snapshot.Age()),
+		Streamers:  snapshot.Streamers,
+	}
+	for _, chromecast := range t.chromecasts {
+		page.Devices = append(page.Devices, deviceOption{chromecast.Name, t.registry.Address(chromecast)})
+	}
+	if snapshot.Err != nil {
+		page.RefreshError = snapshot.Err.Error()
+	}
 
-	onlineStreamers, error := t.twitchService.FetchGames(onlineUsersResponse)
-	if error != nil {
+	// Render into a buffer first so a template error doesn't leave a half written page
+	var buffer bytes.Buffer
+	if err := templates.ExecuteTemplate(&buffer, "channel_list.html", page); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
-		fmt.Println(error)
+		fmt.Println("Error rendering the channel list: ", err)
 		return
 	}
-
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	fmt.Fprintf(w, "%s", "<html><head><link rel=\"stylesheet\" type=\"text/css\" href=\"/static/style.css\"><link rel=\"icon\" type=\"image/x-icon\" href=\"/static/favicon.ico\"/></head><body>")
-	fmt.Fprintf(w, "%s",
-		`<script>
-		  function manualCast(element) {
-				const streamer = document.getElementsByName("sname")[0].value
-				castStreamer(streamer, element)
-			}
-			function castStreamer(streamer, element) {
-				const http = new XMLHttpRequest()
-				const dropDownElement = document.getElementById("device_selection")
-				const ip = dropDownElement.options[dropDownElement.selectedIndex].value
-				const url='/gui/cast/' + streamer + '/' + ip
-				http.open("GET", url)
-
-				http.onreadystatechange = (e) => {
-					if (http.readyState === 4 && http.status === 200) {
-					}
-				}											
-				http.send();
-			}
-		</script>`)
-	fmt.Fprintf(w, "%s", "<div class=\"logoContainer\"><img class=\"logo\" src=\"/static/twitch-logo.png\"></div>")
-
-	fmt.Fprintf(w, "%s", "<select id=\"device_selection\">")
-	for _, chromecast := range t.chromecasts {
-		fmt.Fprintf(w, "<option value=\""+chromecast.IPAddress+"\">"+chromecast.Name+"</option>")
-	}
-	fmt.Fprintf(w, "</select><br>")
-
-	fmt.Fprintf(w, "%s", "<div class=\"manualContainer\"><input type=\"text\" name=\"sname\"><button onclick=\"manualCast(this);\">Manual Cast</button></div>")
-	fmt.Fprintf(w, "%s", "<div class='container'>")
-	for _, user := range onlineStreamers {
-		fmt.Fprintf(w, "%s",
-			"<div class='streamContainer'>"+
-				"<div onclick=\"castStreamer('"+user.Name+"', this);\" class='thumbnailContainer'>"+
-				"<img src=\""+user.ThumbnailURL+"\" class='thumbnailImage'>"+
-				"<div class='viewerCountContainer'><div class='viewerCount'><script>document.write(parseInt("+user.ViewerCount+").toLocaleString()+' viewers')</script></div></div>"+
-				"</div>"+
-				"<div class='streamDetailsContainer'>"+
-				"<div class='profileImageContainer'>"+
-				"<img src=\""+user.ProfileImageURL+"\" class='profileImage'>"+
-				"</div>"+
-				"<div class='textContainer'>"+
-				"<h3>"+user.Title+"</h3>"+
-				"<h4>"+user.Name+"</h4>"+
-				"<h4>"+user.Game+"</h4>"+
-				"</div>"+
-				"</div>"+
-				"</div>")
-	}
-	fmt.Fprintf(w, "%s", "</div>")
-	fmt.Fprintf(w, "%s", "</body></html>")
+	buffer.WriteTo(w)
+}
+
+// describeAge formats an age like 20s or 3m
+func describeAge(age time.Duration) string {
+	if age < time.Minute {
+		return strconv.Itoa(int(age.Seconds())) + "s"
+	}
+	if age < time.Hour {
+		return strconv.Itoa(int(age.Minutes())) + "m"
+	}
+	return strconv.Itoa(int(age.Hours())) + "h"
+}
+
+func describeStatus(status cast.DeviceStatus) string {
+	if status.Error != "" || !status.Online {
+		return "Offline"
+	}
+	if !status.AppRunning {
+		return "Idle"
+	}
+	if status.Streamer != "" {
+		return describePlayerState(status.PlayerState) + " " + status.Streamer
+	}
+	if status.PlayerState != "" {
+		return describePlayerState(status.PlayerState) + " " + status.AppName
+	}
+	return status.AppName
+}
+
+// describePlayerState turns a player state like PLAYING into Playing, the states are always ASCII
+func describePlayerState(state string) string {
+	if state == "" {
+		return ""
+	}
+	return strings.ToUpper(state[:1]) + strings.ToLower(state[1:])
 }
@@ -0,0 +1,323 @@
+package endpoints
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"twitch-caster/cast"
+	"twitch-caster/streams"
+)
+
+// fakeController records the calls it gets instead of talking to a Chromecast
+type fakeController struct {
+	mutex sync.Mutex
+	calls []string
+	err   error
+	// loading holds up Load until it is closed, when set
+	loading chan bool
+}
+
+func (f *fakeController) record(format string, args ...interface{}) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.calls = append(f.calls, fmt.Sprintf(format, args...))
+	return f.err
+}
+
+func (f *fakeController) Load(url string, ipAddress string, streamer string) error {
+	if f.loading != nil {
+		<-f.loading
+	}
+	return f.record("load %s %s %s", ipAddress, streamer, url)
+}
+
+func (f *fakeController) Pause(ipAddress string) error {
+	return f.record("pause %s", ipAddress)
+}
+
+func (f *fakeController) Resume(ipAddress string) error {
+	return f.record("resume %s", ipAddress)
+}
+
+func (f *fakeController) Stop(ipAddress string) error {
+	return f.record("stop %s", ipAddress)
+}
+
+func (f *fakeController) Seek(ipAddress string, seconds int) error {
+	return f.record("seek %s %d", ipAddress, seconds)
+}
+
+func (f *fakeController) SetVolume(ipAddress string, level float32) error {
+	return f.record("volume %s %g", ipAddress, level)
+}
+
+func (f *fakeController) SetMuted(ipAddress string, muted bool) error {
+	return f.record("muted %s %v", ipAddress, muted)
+}
+
+func (f *fakeController) recorded() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]string(nil), f.calls...)
+}
+
+// newTestControlEndpoint creates a TwitchEndpoint whose Chromecasts are controlled by a fakeController
+func newTestControlEndpoint(t *testing.T) (*TwitchEndpoint, *fakeController) {
+	twitchEndpoint := newTestTwitchEndpoint(t, testConfig(true))
+	controller := &fakeController{}
+	twitchEndpoint.castController = controller
+	return twitchEndpoint, controller
+}
+
+// fixedResolver resolves every stream to the same URL
+type fixedResolver struct{}
+
+func (fixedResolver) Resolve(request streams.Request) (streams.Result, error) {
+	return streams.Result{URL: "https://example.com/" + request.Channel + ".m3u8"}, nil
+}
+
+func TestCastTwitch(t *testing.T) {
+	twitchEndpoint, _ := newTestControlEndpoint(t)
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+	}{
+		{"get", http.MethodGet, "/gui/cast/somestreamer/192.168.1.1", http.StatusMethodNotAllowed},
+		{"head", http.MethodHead, "/gui/cast/somestreamer/192.168.1.1", http.StatusMethodNotAllowed},
+		{"unknown device", http.MethodPost, "/gui/cast/somestreamer/192.168.1.9", http.StatusInternalServerError},
+		{"no stream", http.MethodPost, "/gui/cast//192.168.1.1", http.StatusBadRequest},
+		{"stream", http.MethodPost, "/gui/cast/somestreamer/192.168.1.1", http.StatusOK},
+		{"vod", http.MethodPost, "/gui/cast/videos%2F123/192.168.1.1", http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			twitchEndpoint.CastTwitch(recorder, httptest.NewRequest(test.method, test.target, nil))
+			if recorder.Code != test.status {
+				t.Fatalf("status = %d, want %d", recorder.Code, test.status)
+			}
+			if test.status == http.StatusMethodNotAllowed && recorder.Header().Get("Allow") != http.MethodPost {
+				t.Errorf("Allow = %q", recorder.Header().Get("Allow"))
+			}
+			if test.status != http.StatusOK {
+				return
+			}
+			var response castJSONResponse
+			decodeTestJSON(t, recorder, &response)
+			if !response.Success || response.JobID == "" {
+				t.Errorf("response = %+v", response)
+			}
+		})
+	}
+}
+
+func TestControlCast(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		status int
+		call   string
+	}{
+		{"get", http.MethodGet, "/gui/control/pause/192.168.1.1", http.StatusMethodNotAllowed, ""},
+		{"unknown device", http.MethodPost, "/gui/control/pause/192.168.1.9", http.StatusNotFound, ""},
+		{"no device", http.MethodPost, "/gui/control/pause/", http.StatusNotFound, ""},
+		{"unknown action", http.MethodPost, "/gui/control/rewind/192.168.1.1", http.StatusNotFound, ""},
+		{"seek without value", http.MethodPost, "/gui/control/seek/192.168.1.1", http.StatusBadRequest, ""},
+		{"seek fraction", http.MethodPost, "/gui/control/seek/192.168.1.1?value=1.5", http.StatusBadRequest, ""},
+		{"volume without value", http.MethodPost, "/gui/control/volume/192.168.1.1", http.StatusBadRequest, ""},
+		{"volume word", http.MethodPost, "/gui/control/volume/192.168.1.1?value=loud", http.StatusBadRequest, ""},
+		{"volume too high", http.MethodPost, "/gui/control/volume/192.168.1.1?value=1.5", http.StatusBadRequest, ""},
+		{"volume negative", http.MethodPost, "/gui/control/volume/192.168.1.1?value=-0.1", http.StatusBadRequest, ""},
+		{"pause", http.MethodPost, "/gui/control/pause/192.168.1.1", http.StatusOK, "pause 192.168.1.1"},
+		{"resume", http.MethodPost, "/gui/control/resume/192.168.1.1", http.StatusOK, "resume 192.168.1.1"},
+		{"stop", http.MethodPost, "/gui/control/stop/192.168.1.1", http.StatusOK, "stop 192.168.1.1"},
+		{"mute", http.MethodPost, "/gui/control/mute/192.168.1.1", http.StatusOK, "muted 192.168.1.1 true"},
+		{"unmute", http.MethodPost, "/gui/control/unmute/192.168.1.1", http.StatusOK, "muted 192.168.1.1 false"},
+		{"seek", http.MethodPost, "/gui/control/seek/192.168.1.1?value=-30", http.StatusOK, "seek 192.168.1.1 -30"},
+		{"volume", http.MethodPost, "/gui/control/volume/192.168.1.1?value=0.5", http.StatusOK, "volume 192.168.1.1 0.5"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			twitchEndpoint, controller := newTestControlEndpoint(t)
+			recorder := httptest.NewRecorder()
+			twitchEndpoint.ControlCast(recorder, httptest.NewRequest(test.method, test.target, nil))
+			if recorder.Code != test.status {
+				t.Errorf("status = %d, want %d", recorder.Code, test.status)
+			}
+
+			calls := controller.recorded()
+			if test.call == "" {
+				if len(calls) != 0 {
+					t.Errorf("called %v", calls)
+				}
+				return
+			}
+			if len(calls) != 1 || calls[0] != test.call {
+				t.Errorf("called %v, want %s", calls, test.call)
+			}
+			var response castJSONResponse
+			decodeTestJSON(t, recorder, &response)
+			if !response.Success {
+				t.Errorf("response = %+v", response)
+			}
+		})
+	}
+}
+
+func TestControlCastFails(t *testing.T) {
+	twitchEndpoint, controller := newTestControlEndpoint(t)
+	controller.err = errors.New("Chromecast unreachable")
+
+	recorder := httptest.NewRecorder()
+	twitchEndpoint.ControlCast(recorder, httptest.NewRequest(http.MethodPost, "/gui/control/stop/192.168.1.1", nil))
+	if recorder.Code != http.StatusInternalServerError || !strings.Contains(recorder.Body.String(), `"success":false`) {
+		t.Errorf("status = %d, body %s", recorder.Code, recorder.Body)
+	}
+}
+
+// castJobStatus gets the job with id from the job endpoint, or nothing and the status code if it fails
+func castJobStatus(t *testing.T, twitchEndpoint *TwitchEndpoint, id string) (cast.Job, int) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	twitchEndpoint.CastJobStatus(recorder, httptest.NewRequest(http.MethodGet, "/gui/jobs/"+id, nil))
+	var job cast.Job
+	if recorder.Code == http.StatusOK {
+		decodeTestJSON(t, recorder, &job)
+	}
+	return job, recorder.Code
+}
+
+// waitForJobState polls the job endpoint until the job reaches state
+func waitForJobState(t *testing.T, twitchEndpoint *TwitchEndpoint, id string, state cast.JobState) cast.Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		job, status := castJobStatus(t, twitchEndpoint, id)
+		if status != http.StatusOK {
+			t.Fatalf("status = %d", status)
+		}
+		if job.State == state {
+			return job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job = %+v, want state %s", job, state)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCastJobStatus(t *testing.T) {
+	twitchEndpoint, controller := newTestControlEndpoint(t)
+	twitchEndpoint.streamResolver = fixedResolver{}
+	controller.loading = make(chan bool)
+
+	job, err := twitchEndpoint.StartCast("somestreamer", twitchEndpoint.chromecasts[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != cast.JobResolving {
+		t.Errorf("started job = %+v", job)
+	}
+
+	// The stream has been resolved and the Chromecast is loading it
+	waitForJobState(t, twitchEndpoint, job.ID, cast.JobLoading)
+	close(controller.loading)
+	playing := waitForJobState(t, twitchEndpoint, job.ID, cast.JobPlaying)
+	if playing.Streamer != "somestreamer" || playing.IPAddress != "192.168.1.1" || playing.Error != "" {
+		t.Errorf("job = %+v", playing)
+	}
+	if calls := controller.recorded(); len(calls) != 1 || calls[0] != "load 192.168.1.1 somestreamer https://example.com/somestreamer.m3u8" {
+		t.Errorf("called %v", calls)
+	}
+
+	// The Chromecast refusing the stream fails the job
+	controller.err = errors.New("Chromecast refused the stream")
+	job, _ = twitchEndpoint.StartCast("somestreamer", twitchEndpoint.chromecasts[0])
+	failed := waitForJobState(t, twitchEndpoint, job.ID, cast.JobFailed)
+	if failed.Error != "Chromecast refused the stream" {
+		t.Errorf("job = %+v", failed)
+	}
+
+	if _, status := castJobStatus(t, twitchEndpoint, "missing"); status != http.StatusNotFound {
+		t.Errorf("status of an unknown job = %d, want 404", status)
+	}
+}
+
+func TestDescribeAge(t *testing.T) {
+	tests := []struct {
+		age  time.Duration
+		want string
+	}{
+		{0, "0s"},
+		{999 * time.Millisecond, "0s"},
+		{59*time.Second + 999*time.Millisecond, "59s"},
+		{time.Minute, "1m"},
+		{119 * time.Second, "1m"},
+		{59*time.Minute + 59*time.Second, "59m"},
+		{time.Hour, "1h"},
+		{25*time.Hour + 30*time.Minute, "25h"},
+	}
+	for _, test := range tests {
+		if got := describeAge(test.age); got != test.want {
+			t.Errorf("describeAge(%s) = %q, want %q", test.age, got, test.want)
+		}
+	}
+}
+
+func TestDescribeStatus(t *testing.T) {
+	tests := []struct {
+		status cast.DeviceStatus
+		want   string
+	}{
+		{cast.DeviceStatus{Online: true, Error: "Connection refused"}, "Offline"},
+		{cast.DeviceStatus{}, "Offline"},
+		{cast.DeviceStatus{Online: true}, "Idle"},
+		{cast.DeviceStatus{Online: true, AppRunning: true, PlayerState: "PLAYING", Streamer: "SomeStreamer"}, "Playing SomeStreamer"},
+		{cast.DeviceStatus{Online: true, AppRunning: true, PlayerState: "BUFFERING", Streamer: "SomeStreamer"}, "Buffering SomeStreamer"},
+		{cast.DeviceStatus{Online: true, AppRunning: true, PlayerState: "paused", AppName: "YouTube"}, "Paused YouTube"},
+		{cast.DeviceStatus{Online: true, AppRunning: true, AppName: "Backdrop"}, "Backdrop"},
+	}
+	for _, test := range tests {
+		if got := describeStatus(test.status); got != test.want {
+			t.Errorf("describeStatus(%+v) = %q, want %q", test.status, got, test.want)
+		}
+	}
+}
+
+func TestChannelList(t *testing.T) {
+	twitchEndpoint := newTestTwitchEndpoint(t, testConfig(true))
+	recorder := httptest.NewRecorder()
+	twitchEndpoint.TwitchChannelList(recorder, httptest.NewRequest(http.MethodGet, "/gui/twitch-channel-list", nil))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("status = %d", recorder.Code)
+	}
+
+	// The page is rendered straight after the first refresh
+	page := recorder.Body.String()
+	for _, want := range []string{`<div id="updated_container" class="updatedContainer">Updated 0s ago</div>`, "SomeStreamer", "Some Game"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("page doesn't contain %s", want)
+		}
+	}
+	if age := twitchEndpoint.streamPoller.Snapshot().Age(); age < 0 || age > time.Second {
+		t.Errorf("snapshot age = %s", age)
+	}
+}
+
+func TestChannelListNotLoggedIn(t *testing.T) {
+	twitchEndpoint := newTestTwitchEndpoint(t, testConfig(false))
+	recorder := httptest.NewRecorder()
+	twitchEndpoint.TwitchChannelList(recorder, httptest.NewRequest(http.MethodGet, "/gui/twitch-channel-list", nil))
+	if recorder.Code != http.StatusFound || recorder.Header().Get("Location") != "/gui/login" {
+		t.Errorf("status = %d, redirected to %q", recorder.Code, recorder.Header().Get("Location"))
+	}
+}
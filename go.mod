@@ -1,5 +1,10 @@
 module twitch-caster
 
-go 1.13
+go 1.16
 
-require github.com/vishen/go-chromecast v0.2.0
+require (
+	github.com/gogo/protobuf v1.2.1
+	github.com/grandcat/zeroconf v1.0.0
+	github.com/vishen/go-chromecast v0.2.0
+	golang.org/x/net v0.0.0-20210501142056-aec3718b3fa0
+)
@@ -1,20 +1,96 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
 
+	"twitch-caster/auth"
+	"twitch-caster/autocast"
+	"twitch-caster/cast"
 	"twitch-caster/config"
 	"twitch-caster/endpoints"
+	"twitch-caster/services"
+	"twitch-caster/static"
+	"twitch-caster/streams"
 )
 
 func main() {
 	config := config.Load()
 
-	twitchEndpoint := endpoints.NewTwitchEndpoint(config)
+	tokenStore, err := auth.NewEncryptedFileTokenStore(config.Settings.TokenFile, config.Settings.TwitchSecret)
+	if err != nil {
+		log.Fatalln("Error opening the token store: ", err)
+	}
+	authManager := auth.NewManager(config.Settings, tokenStore)
+	if len(os.Args) > 1 && os.Args[1] == "login" {
+		deviceLogin(authManager)
+		return
+	}
 
-	http.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
+	registry := cast.NewRegistry(cast.MDNSBrowser{})
+	registry.Start(context.Background())
+
+	castController := cast.NewController()
+	statusPoller := cast.NewStatusPoller(config.Chromecasts, castController, registry)
+	statusPoller.Start(context.Background())
+
+	streamResolver, err := streams.NewResolver(config.Settings.StreamResolvers)
+	if err != nil {
+		log.Fatalln("Error creating the stream resolver: ", err)
+	}
+
+	authManager.StartValidation(context.Background())
+	twitchService := services.NewTwitchService(config.Settings, authManager)
+	streamPoller := services.NewStreamPoller(twitchService)
+	streamPoller.Start(context.Background())
+
+	staticEndpoint := endpoints.NewStaticEndpoint(static.Files, config.Settings.StaticDir, "/static/")
+
+	twitchEndpoint := endpoints.NewTwitchEndpoint(config, streamPoller, streamResolver, castController, registry, statusPoller, staticEndpoint)
+
+	autoCastEngine, err := autocast.NewEngine(config, twitchService, twitchEndpoint, statusPoller, autocast.SystemClock{})
+	if err != nil {
+		log.Fatalln("Error in the auto cast rules: ", err)
+	}
+	autoCastEngine.Start(context.Background())
+
+	authEndpoint := endpoints.NewAuthEndpoint(config.Settings, authManager)
+
+	adminEndpoint := endpoints.NewAdminEndpoint(twitchService)
+
+	remoteEndpoint := endpoints.NewRemoteEndpoint(twitchEndpoint)
+
+	apiEndpoint := endpoints.NewAPIEndpoint(twitchEndpoint, config.Settings.APIURL)
+
+	http.Handle("/static/", staticEndpoint)
 	http.HandleFunc(config.Settings.ChannelListURL, twitchEndpoint.TwitchChannelList)
 	http.HandleFunc(config.Settings.CastURL, twitchEndpoint.CastTwitch)
+	http.HandleFunc(config.Settings.ControlURL, twitchEndpoint.ControlCast)
+	http.HandleFunc(config.Settings.StatusURL, twitchEndpoint.DeviceStatus)
+	http.HandleFunc(config.Settings.JobsURL, twitchEndpoint.CastJobStatus)
+	http.HandleFunc(config.Settings.EventsURL, twitchEndpoint.StreamEvents)
+	http.Handle(config.Settings.RemoteURL, remoteEndpoint)
+	http.HandleFunc(config.Settings.LoginURL, authEndpoint.Login)
+	http.HandleFunc(config.Settings.AuthCallbackURL, authEndpoint.AuthCallback)
+	http.HandleFunc(config.Settings.CacheURL, adminEndpoint.Cache)
+	apiEndpoint.Register(http.DefaultServeMux)
 	log.Fatal(http.ListenAndServe(":3010", nil))
 }
+
+// deviceLogin logs in with the device code flow, for installs without a browser
+func deviceLogin(authManager *auth.Manager) {
+	authorization, err := authManager.StartDeviceAuthorization(context.Background())
+	if err != nil {
+		log.Fatalln("Error starting Twitch login: ", err)
+	}
+
+	fmt.Println("To log in, visit " + authorization.VerificationURI + " and enter the code " + authorization.UserCode)
+	err = authManager.WaitForDeviceAuthorization(context.Background(), authorization)
+	if err != nil {
+		log.Fatalln("Error logging in to Twitch: ", err)
+	}
+	fmt.Println("Logged in to Twitch as", authManager.UserLogin())
+}
@@ -4,20 +4,55 @@ package models
 type Configuration struct {
 	Settings    Settings     `json:"settings"`
 	Chromecasts []Chromecast `json:"chromecasts"`
+	AutoCast    AutoCast     `json:"autoCast"`
 }
 
 // Settings required to run the application
 type Settings struct {
-	UserID         string `json:"userId"`
-	TwitchClientID string `json:"twitchClientId"`
-	TwitchSecret   string `json:"twitchSecret"`
-	ChannelListURL string `json:"channelListURL"`
-	CastURL        string `json:"castURL"`
+	UserID                string   `json:"userId"`
+	TwitchClientID        string   `json:"twitchClientId"`
+	TwitchSecret          string   `json:"twitchSecret"`
+	TwitchRefreshToken    string   `json:"twitchRefreshToken"`
+	ChannelListURL        string   `json:"channelListURL"`
+	CastURL               string   `json:"castURL"`
+	ControlURL            string   `json:"controlURL"`
+	StatusURL             string   `json:"statusURL"`
+	JobsURL               string   `json:"jobsURL"`
+	StreamResolvers       []string `json:"streamResolvers"`
+	LoginURL              string   `json:"loginURL"`
+	AuthCallbackURL       string   `json:"authCallbackURL"`
+	EventsURL             string   `json:"eventsURL"`
+	RemoteURL             string   `json:"remoteURL"`
+	CacheURL              string   `json:"cacheURL"`
+	APIURL                string   `json:"apiURL"`
+	StaticDir             string   `json:"staticDir"`
+	ExternalURL           string   `json:"externalURL"`
+	TokenFile             string   `json:"tokenFile"`
+	RequestTimeoutSeconds int      `json:"requestTimeoutSeconds"`
 }
 
 // Chromecast objects that are cast targets
 type Chromecast struct {
-	Name       string `json:"name"`
-	IPAddress  string `json:"ipAddress"`
-	QualityMax string `json:"qualityMax"`
+	Name        string `json:"name"`
+	IPAddress   string `json:"ipAddress"`
+	QualityMax  string `json:"qualityMax"`
+	QualityMin  string `json:"qualityMin"`
+	Prefer60FPS bool   `json:"prefer60fps"`
+	AudioOnly   bool   `json:"audioOnly"`
+}
+
+// AutoCast rules start casting a streamer when they go live, DryRun only logs what would be cast
+type AutoCast struct {
+	DryRun bool           `json:"dryRun"`
+	Rules  []AutoCastRule `json:"rules"`
+}
+
+// AutoCastRule casts Streamer to the Chromecast named Device. Quality overrides the device's qualityMax,
+// ActiveHours such as 18:00-23:30 limits when the rule applies, and OnlyIfIdle skips devices that are in use.
+type AutoCastRule struct {
+	Streamer    string `json:"streamer"`
+	Device      string `json:"device"`
+	Quality     string `json:"quality"`
+	ActiveHours string `json:"activeHours"`
+	OnlyIfIdle  bool   `json:"onlyIfIdle"`
 }
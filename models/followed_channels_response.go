@@ -0,0 +1,12 @@
+package models
+
+// FollowedChannelsResponse contains the response payload for a Twitch followed channels request
+type FollowedChannelsResponse struct {
+	Data []struct {
+		BroadcasterID    string `json:"broadcaster_id"`
+		BroadcasterLogin string `json:"broadcaster_login"`
+		BroadcasterName  string `json:"broadcaster_name"`
+		FollowedAt       string `json:"followed_at"`
+	} `json:"data"`
+	Pagination Pagination `json:"pagination"`
+}
@@ -2,10 +2,12 @@ package models
 
 // OnlineStreamer is the model used to represent online streamers
 type OnlineStreamer struct {
-	Name            string
-	Game            string
-	ProfileImageURL string
-	Title           string
-	ThumbnailURL    string
-	ViewerCount     string
+	UserID          string `json:"userId"`
+	Login           string `json:"login"`
+	Name            string `json:"name"`
+	Game            string `json:"game"`
+	ProfileImageURL string `json:"profileImageUrl"`
+	Title           string `json:"title"`
+	ThumbnailURL    string `json:"thumbnailUrl"`
+	ViewerCount     int    `json:"viewerCount"`
 }
@@ -1,7 +1,6 @@
 package models
 
 import (
-	"strconv"
 	"strings"
 )
 
@@ -9,12 +8,14 @@ import (
 type OnlineUsersResponse struct {
 	Data []struct {
 		UserID       string `json:"user_id"`
+		UserLogin    string `json:"user_login"`
 		UserName     string `json:"user_name"`
 		GameID       string `json:"game_id"`
 		Title        string `json:"title"`
 		ThumbnailURL string `json:"thumbnail_url"`
 		ViewerCount  int    `json:"viewer_count"`
 	} `json:"data"`
+	Pagination Pagination `json:"pagination"`
 }
 
 // MakeOnlineStreamers converts an OnlineUsersResponse object into an array of OnlineStreamers object
@@ -29,12 +30,14 @@ func (onlineUsersResponse OnlineUsersResponse) MakeOnlineStreamers(gameIDToNameM
 		}
 
 		onlineStreamer := OnlineStreamer{
+			user.UserID,
+			user.UserLogin,
 			user.UserName,
 			gameName,
 			streamerIDToThumbnailMap[user.UserID],
 			user.Title,
 			thumbnailURL,
-			strconv.Itoa(user.ViewerCount),
+			user.ViewerCount,
 		}
 		onlineStreamers = append(onlineStreamers, onlineStreamer)
 	}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMakeOnlineStreamers(t *testing.T) {
+	body := `{"data":[{"user_id":"1","user_login":"somestreamer","user_name":"SomeStreamer","game_id":"33214","title":"Title",
+		"thumbnail_url":"https://static-cdn.jtvnw.net/previews-ttv/live_user_somestreamer-{width}x{height}.jpg","viewer_count":42}]}`
+	var response OnlineUsersResponse
+	if err := json.Unmarshal([]byte(body), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	streamers := response.MakeOnlineStreamers(map[string]string{"33214": "Fortnite"}, map[string]string{"1": "https://example.com/1.png"})
+	want := OnlineStreamer{
+		UserID:          "1",
+		Login:           "somestreamer",
+		Name:            "SomeStreamer",
+		Game:            "Fortnite",
+		ProfileImageURL: "https://example.com/1.png",
+		Title:           "Title",
+		ThumbnailURL:    "https://static-cdn.jtvnw.net/previews-ttv/live_user_somestreamer-1200x674.jpg",
+		ViewerCount:     42,
+	}
+	if len(streamers) != 1 || streamers[0] != want {
+		t.Errorf("streamers = %+v, want %+v", streamers, want)
+	}
+}
@@ -0,0 +1,6 @@
+package models
+
+// Pagination contains the cursor Twitch returns when there are more results to fetch
+type Pagination struct {
+	Cursor string `json:"cursor"`
+}
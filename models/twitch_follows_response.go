@@ -2,7 +2,8 @@ package models
 
 // TwitchFollowsResponse contains the response payload for a Twitch followers request
 type TwitchFollowsResponse struct {
-	Data []FollowInfo `json:"data"`
+	Data       []FollowInfo `json:"data"`
+	Pagination Pagination   `json:"pagination"`
 }
 
 // FollowInfo is the response data from Twitch
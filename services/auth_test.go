@@ -0,0 +1,115 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"twitch-caster/models"
+)
+
+// rotatingTokenHandler issues app-N and user-N tokens, counting up with every token of that kind it issues
+func rotatingTokenHandler(appTokens *int32, userTokens *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := "app-" + strconv.Itoa(int(atomic.AddInt32(appTokens, 1)))
+		if r.FormValue("grant_type") == "refresh_token" {
+			atomic.AddInt32(appTokens, -1)
+			token = "user-" + strconv.Itoa(int(atomic.AddInt32(userTokens, 1)))
+		}
+		writeTestJSON(w, map[string]interface{}{"access_token": token, "expires_in": 3600})
+	}
+}
+
+// acceptOnly rejects every request not authorized with token
+func acceptOnly(token string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+token {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"error":"Unauthorized","status":401,"message":"Invalid OAuth token"}`))
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func TestConcurrentUnauthorizedRefreshesUserTokenOnce(t *testing.T) {
+	var appTokens, userTokens int32
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{
+		"/oauth2/token":     rotatingTokenHandler(&appTokens, &userTokens),
+		"/streams/followed": acceptOnly("user-2", pagedHandler(makeItems(3, stream), 100)),
+	})
+	twitchService := helix.service(true)
+
+	var wait sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			onlineUsers, err := twitchService.FetchFollowedStreams(context.Background())
+			if err != nil || len(onlineUsers.Data) != 3 {
+				t.Errorf("FetchFollowedStreams() = %d streams, %v", len(onlineUsers.Data), err)
+			}
+		}()
+	}
+	wait.Wait()
+
+	if userTokens != 2 {
+		t.Errorf("fetched %d user tokens, want the rejected one to be replaced once", userTokens)
+	}
+	if appTokens != 0 {
+		t.Errorf("fetched %d app tokens for a user token request", appTokens)
+	}
+}
+
+func TestUnauthorizedRefreshesAppToken(t *testing.T) {
+	var appTokens, userTokens int32
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{
+		"/oauth2/token": rotatingTokenHandler(&appTokens, &userTokens),
+		"/streams":      acceptOnly("app-2", liveStreamsHandler),
+	})
+
+	follows := models.TwitchFollowsResponse{Data: []models.FollowInfo{{ToID: "1"}, {ToID: "2"}}}
+	onlineUsers, err := helix.service(true).FetchTwitchStreamersStatus(context.Background(), follows)
+	if err != nil || len(onlineUsers.Data) != 2 {
+		t.Fatalf("FetchTwitchStreamersStatus() = %d streams, %v", len(onlineUsers.Data), err)
+	}
+	if appTokens != 2 || userTokens != 0 {
+		t.Errorf("fetched %d app and %d user tokens, want 2 app tokens", appTokens, userTokens)
+	}
+}
+
+func TestUnauthorizedTwice(t *testing.T) {
+	var appTokens, userTokens int32
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{
+		"/oauth2/token": rotatingTokenHandler(&appTokens, &userTokens),
+		"/streams":      acceptOnly("never", liveStreamsHandler),
+	})
+
+	follows := models.TwitchFollowsResponse{Data: []models.FollowInfo{{ToID: "1"}}}
+	_, err := helix.service(false).FetchTwitchStreamersStatus(context.Background(), follows)
+	if statusError, ok := err.(*StatusError); !ok || statusError.StatusCode != http.StatusUnauthorized {
+		t.Errorf("error = %v, want the second 401", err)
+	}
+	if requests := len(helix.received("/streams")); requests != 2 {
+		t.Errorf("made %d requests, want the request replayed once", requests)
+	}
+}
+
+func TestRevokedRefreshTokenNeedsLogin(t *testing.T) {
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{
+		"/oauth2/token": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status":400,"message":"Invalid refresh token"}`))
+		},
+	})
+
+	if _, err := helix.service(true).FetchFollowedStreams(context.Background()); err != ErrNotLoggedIn {
+		t.Errorf("error = %v, want ErrNotLoggedIn", err)
+	}
+	if requests := len(helix.received("/streams/followed")); requests != 0 {
+		t.Errorf("made %d requests without a token", requests)
+	}
+}
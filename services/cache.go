@@ -0,0 +1,88 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// How long looked up values are kept, games and profile images rarely change but follows do
+const gameCacheTTL = 24 * time.Hour
+const userCacheTTL = 6 * time.Hour
+const followsCacheTTL = 2 * time.Minute
+
+// CacheStats are the hit and miss counts of a cache since it was created
+type CacheStats struct {
+	Entries int    `json:"entries"`
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+}
+
+type cacheEntry struct {
+	value     interface{}
+	expiresAt time.Time
+}
+
+// ttlCache is a map whose entries expire ttl after they are set
+type ttlCache struct {
+	mutex   sync.Mutex
+	ttl     time.Duration
+	entries map[string]cacheEntry
+	hits    uint64
+	misses  uint64
+
+	// now tells the time entries expire by, tests replace it
+	now func() time.Time
+}
+
+func newTTLCache(ttl time.Duration) *ttlCache {
+	cache := ttlCache{}
+	cache.ttl = ttl
+	cache.entries = make(map[string]cacheEntry)
+	cache.now = time.Now
+	return &cache
+}
+
+// get returns the value saved under key if it hasn't expired
+func (c *ttlCache) get(key string) (interface{}, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if ok && c.now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	return entry.value, true
+}
+
+func (c *ttlCache) set(key string, value interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries[key] = cacheEntry{value, c.now().Add(c.ttl)}
+}
+
+// flush removes every entry, the hit and miss counts are kept
+func (c *ttlCache) flush() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.entries = make(map[string]cacheEntry)
+}
+
+// stats returns the counts, dropping expired entries so they aren't counted
+func (c *ttlCache) stats() CacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+	for key, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	return CacheStats{len(c.entries), c.hits, c.misses}
+}
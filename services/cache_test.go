@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeClock only moves when a test advances it
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// newTestTTLCache creates a cache whose entries expire by clock
+func newTestTTLCache(ttl time.Duration, clock *fakeClock) *ttlCache {
+	cache := newTTLCache(ttl)
+	cache.now = clock.Now
+	return cache
+}
+
+func TestTTLCache(t *testing.T) {
+	clock := &fakeClock{time.Now()}
+	cache := newTestTTLCache(time.Minute, clock)
+	if _, ok := cache.get("game"); ok {
+		t.Error("found a value in an empty cache")
+	}
+
+	cache.set("game", "Fortnite")
+	clock.advance(time.Minute)
+	if value, ok := cache.get("game"); !ok || value != "Fortnite" {
+		t.Errorf("get() = %v, %v", value, ok)
+	}
+	if stats := cache.stats(); stats != (CacheStats{Entries: 1, Hits: 1, Misses: 1}) {
+		t.Errorf("stats = %+v", stats)
+	}
+
+	clock.advance(time.Second)
+	if _, ok := cache.get("game"); ok {
+		t.Error("found an expired value")
+	}
+	if stats := cache.stats(); stats != (CacheStats{Entries: 0, Hits: 1, Misses: 2}) {
+		t.Errorf("stats after expiry = %+v", stats)
+	}
+}
+
+func TestTTLCacheStatsDropExpiredEntries(t *testing.T) {
+	clock := &fakeClock{time.Now()}
+	cache := newTestTTLCache(time.Minute, clock)
+	cache.set("a", 1)
+	cache.set("b", 2)
+	clock.advance(2 * time.Minute)
+	cache.set("c", 3)
+
+	if stats := cache.stats(); stats.Entries != 1 {
+		t.Errorf("counted %d entries, want only the unexpired one", stats.Entries)
+	}
+}
+
+func TestTTLCacheFlushKeepsCounts(t *testing.T) {
+	cache := newTTLCache(time.Hour)
+	cache.set("game", "Fortnite")
+	cache.get("game")
+	cache.get("missing")
+	cache.flush()
+
+	if _, ok := cache.get("game"); ok {
+		t.Error("found a value after flushing")
+	}
+	if stats := cache.stats(); stats != (CacheStats{Entries: 0, Hits: 1, Misses: 2}) {
+		t.Errorf("stats after flush = %+v", stats)
+	}
+}
+
+func TestFetchGamesOnlyFetchesMissingIDs(t *testing.T) {
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{"/games": gamesHandler, "/users": usersHandler})
+	twitchService := helix.service(false)
+
+	lookups := func(path string, key string) []string {
+		var ids []string
+		for _, request := range helix.received(path) {
+			ids = append(ids, strings.Join(request.URL.Query()[key], ","))
+		}
+		return ids
+	}
+
+	for _, ids := range [][]string{{"1", "2"}, {"2", "3"}, {"1", "2", "3"}} {
+		streamers, err := twitchService.FetchGames(context.Background(), streamsResponse(ids...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, streamer := range streamers {
+			if streamer.Game != "Game game"+streamer.UserID || streamer.ProfileImageURL != "https://example.com/"+streamer.UserID+".png" {
+				t.Errorf("streamer = %+v", streamer)
+			}
+		}
+	}
+
+	if got := strings.Join(lookups("/games", "id"), " "); got != "game1,game2 game3" {
+		t.Errorf("looked up games %s, want game1,game2 then game3", got)
+	}
+	if got := strings.Join(lookups("/users", "id"), " "); got != "1,2 3" {
+		t.Errorf("looked up users %s, want 1,2 then 3", got)
+	}
+
+	stats := twitchService.CacheStats()
+	if stats["games"] != (CacheStats{Entries: 3, Hits: 4, Misses: 3}) {
+		t.Errorf("games stats = %+v", stats["games"])
+	}
+
+	twitchService.FlushCache()
+	twitchService.FetchGames(context.Background(), streamsResponse("1"))
+	if got := len(helix.received("/games")); got != 3 {
+		t.Errorf("made %d game lookups, want another after flushing", got)
+	}
+}
+
+func TestFetchLiveLoginsCachesIDs(t *testing.T) {
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{
+		"/users": func(w http.ResponseWriter, r *http.Request) {
+			users := []map[string]interface{}{}
+			for _, login := range r.URL.Query()["login"] {
+				users = append(users, map[string]interface{}{"id": strings.TrimPrefix(login, "user"), "login": login})
+			}
+			writeTestJSON(w, map[string]interface{}{"data": users})
+		},
+		"/streams": func(w http.ResponseWriter, r *http.Request) {
+			writeTestJSON(w, map[string]interface{}{"data": []interface{}{stream("1")}})
+		},
+	})
+	twitchService := helix.service(false)
+
+	for _, logins := range [][]string{{"user1", "User2"}, {"user1", "user2", "user3"}} {
+		live, err := twitchService.FetchLiveLogins(context.Background(), logins)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !live["user1"] || live["user2"] || live["user3"] {
+			t.Errorf("live = %v, want only user1", live)
+		}
+	}
+
+	requests := helix.received("/users")
+	if len(requests) != 2 || strings.Join(requests[1].URL.Query()["login"], ",") != "user3" {
+		t.Errorf("made %d login lookups, want the second to only look up user3", len(requests))
+	}
+}
+
+func TestFetchTwitchFollowsCachesBriefly(t *testing.T) {
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{
+		"/users/follows": pagedHandler(makeItems(3, func(id string) map[string]interface{} {
+			return map[string]interface{}{"to_id": id, "to_name": "User" + id}
+		}), 100),
+	})
+	twitchService := helix.service(false)
+	clock := &fakeClock{time.Now()}
+	twitchService.followsCache.now = clock.Now
+
+	fetch := func(wantRequests int) {
+		t.Helper()
+		follows, err := twitchService.FetchTwitchFollows(context.Background())
+		if err != nil || len(follows.Data) != 3 {
+			t.Fatalf("FetchTwitchFollows() = %d follows, %v", len(follows.Data), err)
+		}
+		if requests := len(helix.received("/users/follows")); requests != wantRequests {
+			t.Fatalf("made %d follows requests, want %d", requests, wantRequests)
+		}
+	}
+
+	fetch(1)
+	clock.advance(followsCacheTTL)
+	fetch(1)
+	if stats := twitchService.CacheStats()["follows"]; stats != (CacheStats{Entries: 1, Hits: 1, Misses: 1}) {
+		t.Errorf("follows stats = %+v", stats)
+	}
+
+	// Follows change far more often than games, so they are looked up again after a couple of minutes
+	clock.advance(time.Second)
+	fetch(2)
+
+	// Flushing from the admin endpoint looks them up again straight away
+	twitchService.FlushCache()
+	fetch(3)
+}
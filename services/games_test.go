@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// gamesHandler names every requested game after its ID
+func gamesHandler(w http.ResponseWriter, r *http.Request) {
+	games := []map[string]interface{}{}
+	for _, id := range r.URL.Query()["id"] {
+		games = append(games, map[string]interface{}{"id": id, "name": "Game " + id})
+	}
+	writeTestJSON(w, map[string]interface{}{"data": games})
+}
+
+// usersHandler gives every requested user a profile image named after their ID
+func usersHandler(w http.ResponseWriter, r *http.Request) {
+	users := []map[string]interface{}{}
+	for _, id := range r.URL.Query()["id"] {
+		users = append(users, map[string]interface{}{"id": id, "login": "user" + id, "profile_image_url": "https://example.com/" + id + ".png"})
+	}
+	writeTestJSON(w, map[string]interface{}{"data": users})
+}
+
+func delayed(delay time.Duration, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		handler(w, r)
+	}
+}
+
+func TestFetchGames(t *testing.T) {
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{"/games": gamesHandler, "/users": usersHandler})
+
+	streamers, err := helix.service(false).FetchGames(context.Background(), streamsResponse("1", "2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streamers) != 2 || streamers[0].Game != "Game game1" || streamers[1].ProfileImageURL != "https://example.com/2.png" {
+		t.Errorf("streamers = %+v", streamers)
+	}
+}
+
+func TestFetchGamesWithoutProfileImages(t *testing.T) {
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{
+		"/games": gamesHandler,
+		"/users": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		},
+	})
+	useShortRetryDelay(t)
+
+	streamers, err := helix.service(false).FetchGames(context.Background(), streamsResponse("1", "2"))
+	if err != nil {
+		t.Fatalf("error = %v, want the streams without profile images", err)
+	}
+	if len(streamers) != 2 {
+		t.Fatalf("got %d streamers, want 2", len(streamers))
+	}
+	for _, streamer := range streamers {
+		if streamer.Login == "" || streamer.Game != "Game game"+streamer.UserID || streamer.ProfileImageURL != "" {
+			t.Errorf("streamer = %+v", streamer)
+		}
+	}
+}
+
+func TestFetchGamesWithoutGameNames(t *testing.T) {
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{
+		"/games": func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+		},
+		"/users": usersHandler,
+	})
+
+	streamers, err := helix.service(false).FetchGames(context.Background(), streamsResponse("1"))
+	if err != nil || len(streamers) != 1 || streamers[0].Game != "Unknown" || streamers[0].ProfileImageURL == "" {
+		t.Errorf("FetchGames() = %+v, %v", streamers, err)
+	}
+}
+
+// BenchmarkFetchGames looks up games and profile images for 250 streams against a server that takes 5ms per request
+func BenchmarkFetchGames(b *testing.B) {
+	helix := newFakeHelix(b, map[string]http.HandlerFunc{
+		"/games": delayed(5*time.Millisecond, gamesHandler),
+		"/users": delayed(5*time.Millisecond, usersHandler),
+	})
+	twitchService := helix.service(false)
+
+	ids := make([]string, 250)
+	for i := range ids {
+		ids[i] = strconv.Itoa(i)
+	}
+	onlineUsers := streamsResponse(ids...)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		twitchService.FlushCache()
+		if _, err := twitchService.FetchGames(context.Background(), onlineUsers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// group runs functions concurrently, cancelling the rest as soon as one of them fails
+type group struct {
+	wait   sync.WaitGroup
+	once   sync.Once
+	err    error
+	cancel context.CancelFunc
+}
+
+// newGroup creates a group whose functions share a context that is cancelled on the first error
+func newGroup(ctx context.Context) (*group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	g := group{}
+	g.cancel = cancel
+	return &g, ctx
+}
+
+// Go runs f in a new goroutine
+func (g *group) Go(f func() error) {
+	g.wait.Add(1)
+	go func() {
+		defer g.wait.Done()
+		if err := f(); err != nil {
+			g.once.Do(func() {
+				g.err = err
+				g.cancel()
+			})
+		}
+	}()
+}
+
+// Wait blocks until every function has returned, then returns the first error
+func (g *group) Wait() error {
+	g.wait.Wait()
+	g.cancel()
+	return g.err
+}
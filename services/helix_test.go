@@ -0,0 +1,125 @@
+package services
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"twitch-caster/auth"
+	"twitch-caster/models"
+)
+
+// fakeHelix serves Helix endpoints and the OAuth token endpoint, recording every request it gets
+type fakeHelix struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	requests map[string][]*http.Request
+}
+
+// newFakeHelix serves handlers by path. Unless a handler is given for them, the token endpoint answers
+// with app-token for app tokens and user-token for user tokens, and every token is valid for user 1.
+func newFakeHelix(t testing.TB, handlers map[string]http.HandlerFunc) *fakeHelix {
+	helix := &fakeHelix{requests: make(map[string][]*http.Request)}
+	helix.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		helix.mutex.Lock()
+		helix.requests[r.URL.Path] = append(helix.requests[r.URL.Path], r)
+		helix.mutex.Unlock()
+
+		handler, ok := handlers[r.URL.Path]
+		if !ok && r.URL.Path == "/oauth2/token" {
+			token := "app-token"
+			if r.FormValue("grant_type") == "refresh_token" {
+				token = "user-token"
+			}
+			writeTestJSON(w, map[string]interface{}{"access_token": token, "expires_in": 3600})
+			return
+		}
+		if !ok && r.URL.Path == "/oauth2/validate" {
+			writeTestJSON(w, map[string]interface{}{"client_id": "client-id", "login": "user1", "user_id": "1"})
+			return
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		handler(w, r)
+	}))
+	t.Cleanup(helix.Close)
+	return helix
+}
+
+// service creates a TwitchService for user 1 that talks to the fake, with a user token if loggedIn
+func (f *fakeHelix) service(loggedIn bool) *TwitchService {
+	settings := models.Settings{UserID: "1", TwitchClientID: "client-id", TwitchSecret: "secret"}
+	if loggedIn {
+		settings.TwitchRefreshToken = "refresh-token"
+	}
+	authManager := auth.NewManagerWithURL(settings, auth.NewMemoryTokenStore(), f.URL)
+	return NewTwitchServiceWithClient(settings, authManager, f.Client(), f.URL)
+}
+
+// received returns the requests made to path
+func (f *fakeHelix) received(path string) []*http.Request {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]*http.Request(nil), f.requests[path]...)
+}
+
+func writeTestJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// pagedHandler serves items in pages of pageSize, with a cursor pointing at the next page
+func pagedHandler(items []map[string]interface{}, pageSize int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.Atoi(r.URL.Query().Get("after"))
+		end := start + pageSize
+		if end > len(items) {
+			end = len(items)
+		}
+
+		cursor := ""
+		if end < len(items) {
+			cursor = strconv.Itoa(end)
+		}
+		writeTestJSON(w, map[string]interface{}{"data": items[start:end], "pagination": map[string]string{"cursor": cursor}})
+	}
+}
+
+// liveStreamsHandler answers /streams lookups as if every requested user is live
+func liveStreamsHandler(w http.ResponseWriter, r *http.Request) {
+	streams := []map[string]interface{}{}
+	for _, id := range r.URL.Query()["user_id"] {
+		streams = append(streams, stream(id))
+	}
+	writeTestJSON(w, map[string]interface{}{"data": streams})
+}
+
+func stream(id string) map[string]interface{} {
+	return map[string]interface{}{"user_id": id, "user_login": "user" + id, "user_name": "User" + id, "game_id": "game" + id, "viewer_count": 10}
+}
+
+func makeItems(count int, item func(id string) map[string]interface{}) []map[string]interface{} {
+	items := make([]map[string]interface{}, 0, count)
+	for i := 0; i < count; i++ {
+		items = append(items, item(strconv.Itoa(1000+i)))
+	}
+	return items
+}
+
+// streamsResponse returns the streams of the given users as Helix would describe them
+func streamsResponse(ids ...string) models.OnlineUsersResponse {
+	streams := make([]map[string]interface{}, 0, len(ids))
+	for _, id := range ids {
+		streams = append(streams, stream(id))
+	}
+	body, _ := json.Marshal(map[string]interface{}{"data": streams})
+
+	var response models.OnlineUsersResponse
+	json.Unmarshal(body, &response)
+	return response
+}
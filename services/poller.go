@@ -0,0 +1,186 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"twitch-caster/models"
+)
+
+const streamPollInterval = 60 * time.Second
+
+// StreamSnapshot is the followed streams as of the last refresh
+type StreamSnapshot struct {
+	Streamers []models.OnlineStreamer
+	// UpdatedAt is when Streamers was last refreshed successfully, zero until the first success
+	UpdatedAt time.Time
+	// Err is the error of the last refresh, nil if it succeeded
+	Err error
+}
+
+// Age returns how long ago the streamers were refreshed
+func (s StreamSnapshot) Age() time.Duration {
+	return time.Since(s.UpdatedAt)
+}
+
+// Stream event types, a streamer is updated when anything shown about their stream changes
+const (
+	StreamAdded   = "added"
+	StreamRemoved = "removed"
+	StreamUpdated = "updated"
+)
+
+// StreamEvent is a change to a single stream between two refreshes
+type StreamEvent struct {
+	Type     string                `json:"type"`
+	Streamer models.OnlineStreamer `json:"streamer"`
+}
+
+// StreamUpdate is sent to subscribers after every refresh, Events is empty when nothing changed or the refresh failed
+type StreamUpdate struct {
+	Events   []StreamEvent
+	Snapshot StreamSnapshot
+}
+
+// Updates are dropped for subscribers this far behind, their channel is closed so they can start over
+const subscriberBuffer = 8
+
+// StreamPoller periodically refreshes the followed streams so pages can be served without waiting on Twitch
+type StreamPoller struct {
+	twitchService *TwitchService
+	snapshot      atomic.Value
+
+	// Held while refreshing so a page load and the background refresh don't both hit Twitch
+	pollMutex sync.Mutex
+
+	subscriberMutex sync.Mutex
+	subscribers     map[chan StreamUpdate]bool
+}
+
+// NewStreamPoller creates a new StreamPoller object
+func NewStreamPoller(twitchService *TwitchService) *StreamPoller {
+	poller := StreamPoller{}
+	poller.twitchService = twitchService
+	poller.snapshot.Store(StreamSnapshot{})
+	poller.subscribers = make(map[chan StreamUpdate]bool)
+	return &poller
+}
+
+// Start refreshes the streams in the background until ctx is cancelled
+func (s *StreamPoller) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(streamPollInterval)
+		defer ticker.Stop()
+
+		for {
+			s.Poll(ctx)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Snapshot returns the streams as of the last refresh
+func (s *StreamPoller) Snapshot() StreamSnapshot {
+	return s.snapshot.Load().(StreamSnapshot)
+}
+
+// Poll refreshes the streams once. When it fails the previous streamers are kept alongside the error.
+func (s *StreamPoller) Poll(ctx context.Context) StreamSnapshot {
+	s.pollMutex.Lock()
+	defer s.pollMutex.Unlock()
+
+	snapshot := s.Snapshot()
+	var events []StreamEvent
+	streamers, err := s.fetch(ctx)
+	if err != nil {
+		fmt.Println("Error refreshing followed streams: ", err)
+		snapshot.Err = err
+	} else {
+		events = DiffStreams(snapshot.Streamers, streamers)
+		snapshot = StreamSnapshot{streamers, time.Now(), nil}
+	}
+	s.snapshot.Store(snapshot)
+	s.publish(StreamUpdate{events, snapshot})
+	return snapshot
+}
+
+// Subscribe returns a channel that receives an update after every refresh, and a function to stop receiving them.
+// The channel is closed if the subscriber falls too far behind.
+func (s *StreamPoller) Subscribe() (<-chan StreamUpdate, func()) {
+	updates := make(chan StreamUpdate, subscriberBuffer)
+
+	s.subscriberMutex.Lock()
+	defer s.subscriberMutex.Unlock()
+	s.subscribers[updates] = true
+
+	return updates, func() {
+		s.subscriberMutex.Lock()
+		defer s.subscriberMutex.Unlock()
+		if s.subscribers[updates] {
+			delete(s.subscribers, updates)
+			close(updates)
+		}
+	}
+}
+
+// SubscriberCount returns how many subscribers are receiving updates
+func (s *StreamPoller) SubscriberCount() int {
+	s.subscriberMutex.Lock()
+	defer s.subscriberMutex.Unlock()
+	return len(s.subscribers)
+}
+
+func (s *StreamPoller) publish(update StreamUpdate) {
+	s.subscriberMutex.Lock()
+	defer s.subscriberMutex.Unlock()
+
+	for updates := range s.subscribers {
+		select {
+		case updates <- update:
+		default:
+			delete(s.subscribers, updates)
+			close(updates)
+		}
+	}
+}
+
+// DiffStreams returns the events that turn the previous streamers into the next ones
+func DiffStreams(previous []models.OnlineStreamer, next []models.OnlineStreamer) []StreamEvent {
+	previousByID := make(map[string]models.OnlineStreamer, len(previous))
+	for _, streamer := range previous {
+		previousByID[streamer.UserID] = streamer
+	}
+
+	events := []StreamEvent{}
+	for _, streamer := range next {
+		old, ok := previousByID[streamer.UserID]
+		if !ok {
+			events = append(events, StreamEvent{StreamAdded, streamer})
+		} else if old != streamer {
+			events = append(events, StreamEvent{StreamUpdated, streamer})
+		}
+		delete(previousByID, streamer.UserID)
+	}
+	for _, streamer := range previous {
+		if _, ok := previousByID[streamer.UserID]; ok {
+			events = append(events, StreamEvent{StreamRemoved, streamer})
+		}
+	}
+	return events
+}
+
+func (s *StreamPoller) fetch(ctx context.Context) ([]models.OnlineStreamer, error) {
+	onlineUsersResponse, err := s.twitchService.FetchFollowedStreams(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.twitchService.FetchGames(ctx, onlineUsersResponse)
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"twitch-caster/models"
+)
+
+func TestDiffStreams(t *testing.T) {
+	alice := models.OnlineStreamer{UserID: "1", Login: "alice", Title: "Speedruns", ViewerCount: 10}
+	bob := models.OnlineStreamer{UserID: "2", Login: "bob", Game: "Chess", ViewerCount: 5}
+	aliceRetitled := alice
+	aliceRetitled.Title = "Any%"
+	bobWatched := bob
+	bobWatched.ViewerCount = 6
+
+	tests := []struct {
+		name     string
+		previous []models.OnlineStreamer
+		next     []models.OnlineStreamer
+		want     []StreamEvent
+	}{
+		{"nothing live", nil, nil, []StreamEvent{}},
+		{"unchanged", []models.OnlineStreamer{alice, bob}, []models.OnlineStreamer{alice, bob}, []StreamEvent{}},
+		{"reordered", []models.OnlineStreamer{alice, bob}, []models.OnlineStreamer{bob, alice}, []StreamEvent{}},
+		{"went live", []models.OnlineStreamer{alice}, []models.OnlineStreamer{alice, bob}, []StreamEvent{{StreamAdded, bob}}},
+		{"first refresh", nil, []models.OnlineStreamer{alice, bob}, []StreamEvent{{StreamAdded, alice}, {StreamAdded, bob}}},
+		{"went offline", []models.OnlineStreamer{alice, bob}, []models.OnlineStreamer{bob}, []StreamEvent{{StreamRemoved, alice}}},
+		{"everyone offline", []models.OnlineStreamer{alice, bob}, nil, []StreamEvent{{StreamRemoved, alice}, {StreamRemoved, bob}}},
+		{"title changed", []models.OnlineStreamer{alice}, []models.OnlineStreamer{aliceRetitled}, []StreamEvent{{StreamUpdated, aliceRetitled}}},
+		{"viewers changed", []models.OnlineStreamer{bob}, []models.OnlineStreamer{bobWatched}, []StreamEvent{{StreamUpdated, bobWatched}}},
+		{"all at once", []models.OnlineStreamer{alice, bob}, []models.OnlineStreamer{bobWatched}, []StreamEvent{{StreamUpdated, bobWatched}, {StreamRemoved, alice}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if events := DiffStreams(test.previous, test.next); !reflect.DeepEqual(events, test.want) {
+				t.Errorf("events = %+v, want %+v", events, test.want)
+			}
+		})
+	}
+}
+
+// newTestStreamPoller polls a fake Helix where count streams are live
+func newTestStreamPoller(t *testing.T, count int) *StreamPoller {
+	empty := func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, map[string]interface{}{"data": []interface{}{}})
+	}
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{
+		"/streams/followed": pagedHandler(makeItems(count, stream), 100),
+		"/games":            empty,
+		"/users":            empty,
+	})
+	return NewStreamPoller(helix.service(true))
+}
+
+func TestSubscribe(t *testing.T) {
+	poller := newTestStreamPoller(t, 2)
+	first, unsubscribeFirst := poller.Subscribe()
+	second, unsubscribeSecond := poller.Subscribe()
+	defer unsubscribeSecond()
+	if count := poller.SubscriberCount(); count != 2 {
+		t.Fatalf("%d subscribers, want 2", count)
+	}
+
+	// Every subscriber gets the same update
+	poller.Poll(context.Background())
+	for _, updates := range []<-chan StreamUpdate{first, second} {
+		update := <-updates
+		if len(update.Events) != 2 || update.Events[0].Type != StreamAdded || len(update.Snapshot.Streamers) != 2 {
+			t.Errorf("update = %+v", update)
+		}
+	}
+
+	// Unsubscribing closes the channel, and can safely be done twice
+	unsubscribeFirst()
+	unsubscribeFirst()
+	if _, ok := <-first; ok {
+		t.Error("received an update after unsubscribing")
+	}
+	if count := poller.SubscriberCount(); count != 1 {
+		t.Errorf("%d subscribers after unsubscribing, want 1", count)
+	}
+
+	// Nothing changed, but subscribers still learn about the refresh
+	poller.Poll(context.Background())
+	if update := <-second; len(update.Events) != 0 || update.Snapshot.UpdatedAt.IsZero() {
+		t.Errorf("update = %+v", update)
+	}
+}
+
+func TestSubscriberFallingBehind(t *testing.T) {
+	poller := newTestStreamPoller(t, 1)
+	updates, unsubscribe := poller.Subscribe()
+	defer unsubscribe()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		poller.Poll(context.Background())
+	}
+	if count := poller.SubscriberCount(); count != 0 {
+		t.Errorf("%d subscribers, want the one that fell behind dropped", count)
+	}
+
+	// The buffered updates can still be read before the channel ends
+	received := 0
+	for range updates {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("received %d updates, want %d", received, subscriberBuffer)
+	}
+}
+
+func TestSnapshotAge(t *testing.T) {
+	snapshot := StreamSnapshot{UpdatedAt: time.Now().Add(-90 * time.Second)}
+	if age := snapshot.Age(); age < 90*time.Second || age > 91*time.Second {
+		t.Errorf("Age() = %s, want 90s", age)
+	}
+}
+
+func TestPollKeepsUpdatedAtWhenFailing(t *testing.T) {
+	var failing int32
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{
+		"/streams/followed": func(w http.ResponseWriter, r *http.Request) {
+			if atomic.LoadInt32(&failing) == 1 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			pagedHandler(makeItems(1, stream), 100)(w, r)
+		},
+		"/games": func(w http.ResponseWriter, r *http.Request) {
+			writeTestJSON(w, map[string]interface{}{"data": []interface{}{}})
+		},
+		"/users": func(w http.ResponseWriter, r *http.Request) {
+			writeTestJSON(w, map[string]interface{}{"data": []interface{}{}})
+		},
+	})
+	poller := NewStreamPoller(helix.service(true))
+	if age := poller.Snapshot().Age(); age < time.Hour {
+		t.Errorf("Age() before the first refresh = %s", age)
+	}
+
+	refreshed := poller.Poll(context.Background())
+	if refreshed.Err != nil || refreshed.Age() > time.Second {
+		t.Fatalf("snapshot = %+v, age %s", refreshed, refreshed.Age())
+	}
+
+	// A failed refresh keeps the time of the last success, so the page shows how old the streams are
+	atomic.StoreInt32(&failing, 1)
+	failed := poller.Poll(context.Background())
+	if failed.Err == nil || !failed.UpdatedAt.Equal(refreshed.UpdatedAt) || len(failed.Streamers) != 1 {
+		t.Errorf("snapshot = %+v, want the last success kept", failed)
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Requests are held back once the bucket drops to this many points, leaving room for concurrent page loads
+const rateLimitReserve = 5
+
+// Retries for 429 and 5xx responses back off exponentially from this delay, tests shorten it
+var retryBaseDelay = 500 * time.Millisecond
+
+const maxRetries = 3
+
+// RateLimitBudget is the state of the Helix rate limit bucket as of the last response
+type RateLimitBudget struct {
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Reset     time.Time `json:"reset"`
+}
+
+// rateLimiter tracks the Ratelimit-* headers Helix returns and delays requests when the bucket runs low
+type rateLimiter struct {
+	mutex  sync.Mutex
+	budget RateLimitBudget
+	known  bool
+}
+
+// RateLimitBudget returns the rate limit budget as of the last response
+func (c *Client) RateLimitBudget() RateLimitBudget {
+	c.limiter.mutex.Lock()
+	defer c.limiter.mutex.Unlock()
+	return c.limiter.budget
+}
+
+// wait blocks until the bucket has room for another request, then takes a point from it.
+// The lock is only held to check the bucket so responses can update it and the budget can be read meanwhile.
+func (r *rateLimiter) wait(ctx context.Context) error {
+	for {
+		delay, budget := r.take()
+		if delay <= 0 {
+			return nil
+		}
+		fmt.Printf("Helix rate limit budget low (%d/%d), waiting %v\n", budget.Remaining, budget.Limit, delay.Round(time.Millisecond))
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// take takes a point from the bucket if it has room, otherwise it returns how long until the bucket refills
+func (r *rateLimiter) take() (time.Duration, RateLimitBudget) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.known && r.budget.Remaining <= rateLimitReserve {
+		if delay := time.Until(r.budget.Reset); delay > 0 {
+			return delay, r.budget
+		}
+		// The bucket has refilled, the next response says by how much
+		r.known = false
+	}
+	if r.known {
+		r.budget.Remaining--
+	}
+	return 0, r.budget
+}
+
+// update records the bucket state from the Ratelimit-* headers of a response
+func (r *rateLimiter) update(header http.Header) {
+	limit, limitError := strconv.Atoi(header.Get("Ratelimit-Limit"))
+	remaining, remainingError := strconv.Atoi(header.Get("Ratelimit-Remaining"))
+	reset, resetError := strconv.ParseInt(header.Get("Ratelimit-Reset"), 10, 64)
+	if limitError != nil || remainingError != nil || resetError != nil {
+		return
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.budget = RateLimitBudget{limit, remaining, time.Unix(reset, 0)}
+	r.known = true
+}
+
+// retryDelay is how long to wait before retrying a 429 or 5xx response
+func retryDelay(res *http.Response, attempt int) time.Duration {
+	if res.StatusCode == http.StatusTooManyRequests {
+		if reset, err := strconv.ParseInt(res.Header.Get("Ratelimit-Reset"), 10, 64); err == nil {
+			if delay := time.Until(time.Unix(reset, 0)); delay > 0 {
+				return delay
+			}
+		}
+	}
+
+	delay := retryBaseDelay << uint(attempt)
+	jitter := time.Duration(rand.Int63n(int64(delay / 2)))
+	return delay + jitter
+}
+
+// sleep waits for delay, returning early with the context's error if it is cancelled first
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
@@ -0,0 +1,185 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useShortRetryDelay makes retries back off from 1ms for the rest of the test
+func useShortRetryDelay(t *testing.T) {
+	retryBaseDelay = time.Millisecond
+	t.Cleanup(func() { retryBaseDelay = 500 * time.Millisecond })
+}
+
+// statusSequence answers with each of statuses in turn, repeating the last one, and counts the requests it gets
+func statusSequence(requests *int32, statuses ...int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count := int(atomic.AddInt32(requests, 1))
+		status := statuses[len(statuses)-1]
+		if count <= len(statuses) {
+			status = statuses[count-1]
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"data":[]}`))
+	}))
+}
+
+func TestMakeRequestRetries(t *testing.T) {
+	useShortRetryDelay(t)
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantRequests int32
+		wantStatus   int
+	}{
+		{name: "success", statuses: []int{200}, wantRequests: 1},
+		{name: "recovers from 5xx", statuses: []int{503, 502, 200}, wantRequests: 3},
+		{name: "recovers from 429", statuses: []int{429, 200}, wantRequests: 2},
+		{name: "gives up after retries", statuses: []int{500}, wantRequests: maxRetries + 1, wantStatus: 500},
+		{name: "client errors are not retried", statuses: []int{404}, wantRequests: 1, wantStatus: 404},
+		{name: "unauthorized is not retried", statuses: []int{401}, wantRequests: 1, wantStatus: 401},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests int32
+			server := statusSequence(&requests, test.statuses...)
+			defer server.Close()
+
+			client := NewClient(server.Client(), time.Second)
+			var response struct{}
+			err := client.MakeRequest(context.Background(), Request{"GET", server.URL, nil, nil}, &response)
+			if requests != test.wantRequests {
+				t.Errorf("made %d requests, want %d", requests, test.wantRequests)
+			}
+			if test.wantStatus == 0 {
+				if err != nil {
+					t.Errorf("error = %v", err)
+				}
+				return
+			}
+			if statusError, ok := err.(*StatusError); !ok || statusError.StatusCode != test.wantStatus {
+				t.Errorf("error = %v, want status %d", err, test.wantStatus)
+			}
+		})
+	}
+}
+
+// The wait for the rate limit bucket to refill is longer than the timeout, which only applies to each round trip
+func TestRateLimitWaitIsNotBoundByTimeout(t *testing.T) {
+	reset := time.Now().Add(1500 * time.Millisecond).Unix()
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Ratelimit-Limit", "800")
+		w.Header().Set("Ratelimit-Remaining", "3")
+		w.Header().Set("Ratelimit-Reset", strconv.FormatInt(reset, 10))
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.Client(), 200*time.Millisecond)
+	var response struct{}
+	if err := client.MakeRequest(context.Background(), Request{"GET", server.URL, nil, nil}, &response); err != nil {
+		t.Fatal(err)
+	}
+	budget := client.RateLimitBudget()
+	if budget.Limit != 800 || budget.Remaining != 3 || budget.Reset.Unix() != reset {
+		t.Errorf("budget = %+v", budget)
+	}
+
+	start := time.Now()
+	if err := client.MakeRequest(context.Background(), Request{"GET", server.URL, nil, nil}, &response); err != nil {
+		t.Fatalf("request after waiting for the budget failed: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("sent the request after %v, want it held until the budget reset", elapsed)
+	}
+	if requests != 2 {
+		t.Errorf("made %d requests, want 2", requests)
+	}
+}
+
+func TestTooManyRequestsWaitsForReset(t *testing.T) {
+	var requests int32
+	var reset int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			reset = time.Now().Add(1500 * time.Millisecond).Unix()
+			w.Header().Set("Ratelimit-Limit", "800")
+			w.Header().Set("Ratelimit-Remaining", "0")
+			w.Header().Set("Ratelimit-Reset", strconv.FormatInt(reset, 10))
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.Client(), 200*time.Millisecond)
+	var response struct{}
+	if err := client.MakeRequest(context.Background(), Request{"GET", server.URL, nil, nil}, &response); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 2 {
+		t.Errorf("made %d requests, want 2", requests)
+	}
+	if time.Now().Before(time.Unix(reset, 0)) {
+		t.Error("retried before the rate limit reset")
+	}
+}
+
+func TestRateLimitWaitStopsWhenCancelled(t *testing.T) {
+	limiter := &rateLimiter{}
+	header := http.Header{}
+	header.Set("Ratelimit-Limit", "800")
+	header.Set("Ratelimit-Remaining", "0")
+	header.Set("Ratelimit-Reset", strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+	limiter.update(header)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := limiter.wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+// A request waiting for the bucket to refill must not hold up responses updating it or the admin page reading it
+func TestRateLimitWaitDoesNotBlockUpdates(t *testing.T) {
+	client := NewClient(http.DefaultClient, time.Second)
+	header := http.Header{}
+	header.Set("Ratelimit-Limit", "800")
+	header.Set("Ratelimit-Remaining", "0")
+	header.Set("Ratelimit-Reset", strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10))
+	client.limiter.update(header)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	waited := make(chan error)
+	go func() { waited <- client.limiter.wait(ctx) }()
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan RateLimitBudget)
+	go func() {
+		header.Set("Ratelimit-Remaining", "1")
+		client.limiter.update(header)
+		done <- client.RateLimitBudget()
+	}()
+	select {
+	case budget := <-done:
+		if budget.Remaining != 1 {
+			t.Errorf("remaining = %d, want 1", budget.Remaining)
+		}
+	case <-time.After(time.Second):
+		t.Error("updating and reading the budget blocked while a request waited")
+	}
+
+	cancel()
+	if err := <-waited; err != context.Canceled {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+}
@@ -1,6 +1,7 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 // Request is a generic struct that contains information about how to make a network request
@@ -18,12 +20,37 @@ type Request struct {
 	queryParameters map[string][]string
 }
 
-var client = http.Client{}
+// Client makes network requests, pacing them by the rate limit headers the server returns
+type Client struct {
+	httpClient *http.Client
+	limiter    *rateLimiter
+	timeout    time.Duration
+}
+
+// NewClient creates a new Client object that sends requests with httpClient, giving each attempt timeout to complete
+func NewClient(httpClient *http.Client, timeout time.Duration) *Client {
+	client := Client{}
+	client.httpClient = httpClient
+	client.limiter = &rateLimiter{}
+	client.timeout = timeout
+	return &client
+}
+
+// StatusError is returned by MakeRequest when the server responds with anything other than 200
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return "Error making request, got status code " + strconv.Itoa(e.StatusCode) + " " + e.Body
+}
 
-// MakeRequest makes a network request and unmarshalls the data
-func MakeRequest(request Request, responseObject interface{}) error {
+// MakeRequest makes a network request and unmarshalls the data, giving up when ctx is done.
+// The timeout only bounds each round trip, waiting on the rate limit or between retries doesn't count against it.
+func (c *Client) MakeRequest(ctx context.Context, request Request, responseObject interface{}) error {
 
-	req, _ := http.NewRequest(request.method, request.url, nil)
+	req, _ := http.NewRequestWithContext(ctx, request.method, request.url, nil)
 	for key, value := range request.headers {
 		req.Header.Set(key, value)
 	}
@@ -36,20 +63,33 @@ func MakeRequest(request Request, responseObject interface{}) error {
 	}
 	req.URL.RawQuery = queryParams.Encode()
 
-	res, error := client.Do(req)
-	if error != nil {
-		fmt.Println(error)
-		return error
-	}
-	defer res.Body.Close()
+	var res *http.Response
+	var body []byte
+	for attempt := 0; ; attempt++ {
+		if error := c.limiter.wait(ctx); error != nil {
+			return error
+		}
+
+		var error error
+		res, body, error = c.roundTrip(ctx, req)
+		if error != nil {
+			fmt.Println(error)
+			return error
+		}
+		c.limiter.update(res.Header)
 
-	body, error := ioutil.ReadAll(res.Body)
-	if error != nil {
-		return errors.New("Error reading response")
+		if !isRetryableStatus(res.StatusCode) || attempt == maxRetries {
+			break
+		}
+		delay := retryDelay(res, attempt)
+		fmt.Printf("Got status code %d from %s, retrying in %v\n", res.StatusCode, request.url, delay.Round(time.Millisecond))
+		if error := sleep(ctx, delay); error != nil {
+			return error
+		}
 	}
 
 	if res.StatusCode != 200 {
-		return errors.New("Error making request, got status code " + strconv.Itoa(res.StatusCode) + " " + string(body))
+		return &StatusError{res.StatusCode, string(body)}
 	}
 
 	err := json.Unmarshal(body, &responseObject)
@@ -59,3 +99,21 @@ func MakeRequest(request Request, responseObject interface{}) error {
 	}
 	return nil
 }
+
+// roundTrip sends req and reads the response body, giving up after the client's timeout
+func (c *Client) roundTrip(ctx context.Context, req *http.Request) (*http.Response, []byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	res, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, nil, errors.New("Error reading response")
+	}
+	return res, body, nil
+}
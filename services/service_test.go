@@ -0,0 +1,104 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"twitch-caster/models"
+)
+
+func hang(w http.ResponseWriter, r *http.Request) {
+	<-r.Context().Done()
+}
+
+func TestFetchFollowedStreamsCancelled(t *testing.T) {
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{"/streams/followed": hang})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := helix.service(true).FetchFollowedStreams(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned %v after being cancelled", elapsed)
+	}
+}
+
+func TestFetchFollowedStreamsTimesOut(t *testing.T) {
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{"/streams/followed": hang})
+	twitchService := helix.service(true)
+	twitchService.client.timeout = 50 * time.Millisecond
+
+	start := time.Now()
+	_, err := twitchService.FetchFollowedStreams(context.Background())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want context.DeadlineExceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("returned after %v, want the 50ms timeout", elapsed)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	if timeout := requestTimeout(models.Settings{}); timeout != defaultRequestTimeout {
+		t.Errorf("default timeout = %v, want %v", timeout, defaultRequestTimeout)
+	}
+	if timeout := requestTimeout(models.Settings{RequestTimeoutSeconds: 3}); timeout != 3*time.Second {
+		t.Errorf("configured timeout = %v, want 3s", timeout)
+	}
+}
+
+func TestFetchGamesCancelled(t *testing.T) {
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{"/games": hang, "/users": hang})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err := helix.service(false).FetchGames(ctx, streamsResponse("1", "2"))
+	if err != context.Canceled {
+		t.Errorf("error = %v, want context.Canceled", err)
+	}
+}
+
+func TestPollKeepsStreamsWhenCancelled(t *testing.T) {
+	block := make(chan bool)
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{
+		"/streams/followed": func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-block:
+				hang(w, r)
+			default:
+				pagedHandler(makeItems(2, stream), 100)(w, r)
+			}
+		},
+		"/games": func(w http.ResponseWriter, r *http.Request) {
+			writeTestJSON(w, map[string]interface{}{"data": []interface{}{}})
+		},
+		"/users": func(w http.ResponseWriter, r *http.Request) {
+			writeTestJSON(w, map[string]interface{}{"data": []interface{}{}})
+		},
+	})
+	poller := NewStreamPoller(helix.service(true))
+
+	poller.Poll(context.Background())
+	if snapshot := poller.Snapshot(); snapshot.Err != nil || len(snapshot.Streamers) != 2 {
+		t.Fatalf("snapshot = %+v", snapshot)
+	}
+
+	close(block)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	poller.Poll(ctx)
+	snapshot := poller.Snapshot()
+	if !errors.Is(snapshot.Err, context.DeadlineExceeded) {
+		t.Errorf("snapshot error = %v, want context.DeadlineExceeded", snapshot.Err)
+	}
+	if len(snapshot.Streamers) != 2 {
+		t.Errorf("kept %d streamers after a failed refresh, want 2", len(snapshot.Streamers))
+	}
+}
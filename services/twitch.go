@@ -1,164 +1,567 @@
 package services
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
 	"twitch-caster/auth"
 	"twitch-caster/models"
 )
 
-const followedStreamersURL = "https://api.twitch.tv/helix/users/follows"
-const streamStatusURL = "https://api.twitch.tv/helix/streams"
-const gamesURL = "https://api.twitch.tv/helix/games"
-const usersURL = "https://api.twitch.tv/helix/users"
+const defaultHelixURL = "https://api.twitch.tv/helix"
+const defaultRequestTimeout = 10 * time.Second
+
+// followedStreamersPath is the retired follows endpoint, only used when no user token is available
+const followedStreamersPath = "/users/follows"
+const followedChannelsPath = "/channels/followed"
+const followedStreamsPath = "/streams/followed"
+const streamStatusPath = "/streams"
+const gamesPath = "/games"
+const usersPath = "/users"
+
+// Helix returns at most 100 results per page and accepts at most 100 IDs per lookup
+const maxPageSize = 100
 
 var endpoints = map[string]endpoint{
-	"TWITCH_FOLLOWERS":        {"GET", followedStreamersURL},
-	"TWITCH_STREAMERS_STATUS": {"GET", streamStatusURL},
-	"TWITCH_GAMES":            {"GET", gamesURL},
-	"TWITCH_USERS":            {"GET", usersURL},
+	"TWITCH_FOLLOWERS":         {"GET", followedStreamersPath},
+	"TWITCH_FOLLOWED_CHANNELS": {"GET", followedChannelsPath},
+	"TWITCH_FOLLOWED_STREAMS":  {"GET", followedStreamsPath},
+	"TWITCH_STREAMERS_STATUS":  {"GET", streamStatusPath},
+	"TWITCH_GAMES":             {"GET", gamesPath},
+	"TWITCH_USERS":             {"GET", usersPath},
 }
 
 type endpoint struct {
 	method string
-	url    string
+	path   string
 }
 
 // TwitchService is a struct that has methods related to making Twitch API requests
 type TwitchService struct {
 	settings    models.Settings
 	authManager *auth.Manager
+	client      *Client
+	baseURL     string
+
+	gameCache    *ttlCache
+	userCache    *ttlCache
+	followsCache *ttlCache
+	loginCache   *ttlCache
 }
 
+// ErrNotLoggedIn is returned when there is no configured user ID and nobody has logged in
+var ErrNotLoggedIn = errors.New("No Twitch user, log in or set twitchRefreshToken in configuration.json")
+
 // NewTwitchService creates a new TwitchService object
-func NewTwitchService(settings models.Settings) *TwitchService {
+func NewTwitchService(settings models.Settings, authManager *auth.Manager) *TwitchService {
+	return NewTwitchServiceWithClient(settings, authManager, &http.Client{}, defaultHelixURL)
+}
+
+// NewTwitchServiceWithClient creates a TwitchService that sends requests to baseURL with httpClient
+func NewTwitchServiceWithClient(settings models.Settings, authManager *auth.Manager, httpClient *http.Client, baseURL string) *TwitchService {
 	twitchService := TwitchService{}
 	twitchService.settings = settings
-	twitchService.authManager = auth.NewManager(settings)
+	twitchService.authManager = authManager
+	twitchService.client = NewClient(httpClient, requestTimeout(settings))
+	twitchService.baseURL = strings.TrimSuffix(baseURL, "/")
+	twitchService.gameCache = newTTLCache(gameCacheTTL)
+	twitchService.userCache = newTTLCache(userCacheTTL)
+	twitchService.followsCache = newTTLCache(followsCacheTTL)
+	twitchService.loginCache = newTTLCache(userCacheTTL)
 	return &twitchService
 }
 
-// FetchTwitchFollows fetches the followed streamers for a Twitch user
-func (t *TwitchService) FetchTwitchFollows() (models.TwitchFollowsResponse, error) {
+// CacheStats returns the statistics of each cache by name
+func (t *TwitchService) CacheStats() map[string]CacheStats {
+	return map[string]CacheStats{
+		"games":   t.gameCache.stats(),
+		"users":   t.userCache.stats(),
+		"follows": t.followsCache.stats(),
+		"logins":  t.loginCache.stats(),
+	}
+}
+
+// FlushCache forgets every cached game, user, follows list and login
+func (t *TwitchService) FlushCache() {
+	t.gameCache.flush()
+	t.userCache.flush()
+	t.followsCache.flush()
+	t.loginCache.flush()
+}
+
+// RateLimitBudget returns the Helix rate limit budget as of the last response
+func (t *TwitchService) RateLimitBudget() RateLimitBudget {
+	return t.client.RateLimitBudget()
+}
+
+func requestTimeout(settings models.Settings) time.Duration {
+	if settings.RequestTimeoutSeconds > 0 {
+		return time.Duration(settings.RequestTimeoutSeconds) * time.Second
+	}
+	return defaultRequestTimeout
+}
+
+// FetchFollowedStreams fetches the live streams of every channel the user follows.
+// Without a user token it falls back to looking up the follows and then their streams.
+func (t *TwitchService) FetchFollowedStreams(ctx context.Context) (models.OnlineUsersResponse, error) {
+	if !t.authManager.HasUserToken() && t.authManager.UserID() == "" {
+		return models.OnlineUsersResponse{}, ErrNotLoggedIn
+	}
+
+	if !t.authManager.HasUserToken() {
+		twitchFollowsResponse, err := t.FetchTwitchFollows(ctx)
+		if err != nil {
+			return models.OnlineUsersResponse{}, err
+		}
+		return t.FetchTwitchStreamersStatus(ctx, twitchFollowsResponse)
+	}
+
+	var onlineUsersResponse models.OnlineUsersResponse
+	var endpoint = endpoints["TWITCH_FOLLOWED_STREAMS"]
+
+	headers := map[string]string{}
+	t.appendCommonHeaders(headers)
+	err := t.appendUserAuthHeader(ctx, headers)
+	if err != nil {
+		return onlineUsersResponse, err
+	}
+
+	cursor := ""
+	userID := t.authManager.UserID()
+	for {
+		queryParameters := map[string][]string{"user_id": {userID}, "first": {strconv.Itoa(maxPageSize)}}
+		if cursor != "" {
+			queryParameters["after"] = []string{cursor}
+		}
+
+		var page models.OnlineUsersResponse
+		request := Request{endpoint.method, t.baseURL + endpoint.path, headers, queryParameters}
+		err = t.makeAuthorizedRequest(ctx, request, userToken, &page)
+		if err != nil {
+			return onlineUsersResponse, err
+		}
+
+		onlineUsersResponse.Data = append(onlineUsersResponse.Data, page.Data...)
+		cursor = page.Pagination.Cursor
+		if cursor == "" || len(page.Data) == 0 {
+			return onlineUsersResponse, nil
+		}
+	}
+}
+
+// FetchTwitchFollows fetches every followed streamer for a Twitch user, following the pagination cursor.
+// Without a user token it falls back to the retired users/follows endpoint.
+func (t *TwitchService) FetchTwitchFollows(ctx context.Context) (models.TwitchFollowsResponse, error) {
+	userID := t.authManager.UserID()
+	if cached, ok := t.followsCache.get(userID); ok {
+		return cached.(models.TwitchFollowsResponse), nil
+	}
+
+	twitchFollowersData, err := t.fetchTwitchFollows(ctx)
+	if err == nil {
+		t.followsCache.set(userID, twitchFollowersData)
+	}
+	return twitchFollowersData, err
+}
+
+func (t *TwitchService) fetchTwitchFollows(ctx context.Context) (models.TwitchFollowsResponse, error) {
+	if t.authManager.HasUserToken() {
+		return t.fetchFollowedChannels(ctx)
+	}
+
 	var twitchFollowersData models.TwitchFollowsResponse
 	var endpoint = endpoints["TWITCH_FOLLOWERS"]
 
 	headers := map[string]string{}
 	t.appendCommonHeaders(headers)
-	err := t.appendTwitchAuthHeader(headers)
+	err := t.appendTwitchAuthHeader(ctx, headers)
 	if err != nil {
 		return twitchFollowersData, err
 	}
 
-	queryParameters := map[string][]string{"from_id": {t.settings.UserID}, "first": {"100"}}
+	cursor := ""
+	userID := t.authManager.UserID()
+	if userID == "" {
+		return twitchFollowersData, ErrNotLoggedIn
+	}
+	for {
+		queryParameters := map[string][]string{"from_id": {userID}, "first": {strconv.Itoa(maxPageSize)}}
+		if cursor != "" {
+			queryParameters["after"] = []string{cursor}
+		}
+
+		var page models.TwitchFollowsResponse
+		request := Request{endpoint.method, t.baseURL + endpoint.path, headers, queryParameters}
+		err = t.makeAuthorizedRequest(ctx, request, appToken, &page)
+		if err != nil {
+			return twitchFollowersData, err
+		}
+
+		twitchFollowersData.Data = append(twitchFollowersData.Data, page.Data...)
+		cursor = page.Pagination.Cursor
+		if cursor == "" || len(page.Data) == 0 {
+			return twitchFollowersData, nil
+		}
+	}
+}
 
-	request := Request{endpoint.method, endpoint.url, headers, queryParameters}
-	err = MakeRequest(request, &twitchFollowersData)
+func (t *TwitchService) fetchFollowedChannels(ctx context.Context) (models.TwitchFollowsResponse, error) {
+	var twitchFollowersData models.TwitchFollowsResponse
+	var endpoint = endpoints["TWITCH_FOLLOWED_CHANNELS"]
 
-	return twitchFollowersData, err
+	headers := map[string]string{}
+	t.appendCommonHeaders(headers)
+	err := t.appendUserAuthHeader(ctx, headers)
+	if err != nil {
+		return twitchFollowersData, err
+	}
+
+	cursor := ""
+	userID := t.authManager.UserID()
+	for {
+		queryParameters := map[string][]string{"user_id": {userID}, "first": {strconv.Itoa(maxPageSize)}}
+		if cursor != "" {
+			queryParameters["after"] = []string{cursor}
+		}
+
+		var page models.FollowedChannelsResponse
+		request := Request{endpoint.method, t.baseURL + endpoint.path, headers, queryParameters}
+		err = t.makeAuthorizedRequest(ctx, request, userToken, &page)
+		if err != nil {
+			return twitchFollowersData, err
+		}
+
+		for _, channel := range page.Data {
+			twitchFollowersData.Data = append(twitchFollowersData.Data, models.FollowInfo{ToID: channel.BroadcasterID, ToName: channel.BroadcasterName})
+		}
+		cursor = page.Pagination.Cursor
+		if cursor == "" || len(page.Data) == 0 {
+			return twitchFollowersData, nil
+		}
+	}
 }
 
 // FetchTwitchStreamersStatus calls the Twitch API to get additional information about streamers
-func (t *TwitchService) FetchTwitchStreamersStatus(twitchFollowsResponse models.TwitchFollowsResponse) (models.OnlineUsersResponse, error) {
+func (t *TwitchService) FetchTwitchStreamersStatus(ctx context.Context, twitchFollowsResponse models.TwitchFollowsResponse) (models.OnlineUsersResponse, error) {
 	var onlineUsersResponse models.OnlineUsersResponse
 	var endpoint = endpoints["TWITCH_STREAMERS_STATUS"]
 
 	headers := map[string]string{}
 	t.appendCommonHeaders(headers)
-	err := t.appendTwitchAuthHeader(headers)
+	err := t.appendTwitchAuthHeader(ctx, headers)
 	if err != nil {
 		return onlineUsersResponse, err
 	}
 
-	queryParameters := map[string][]string{}
-	queryParameters["first"] = []string{"100"}
-	queryParameters["user_id"] = []string{}
+	userIDs := make([]string, 0, len(twitchFollowsResponse.Data))
 	for _, element := range twitchFollowsResponse.Data {
-		queryParameters["user_id"] = append(queryParameters["user_id"], element.ToID)
+		userIDs = append(userIDs, element.ToID)
 	}
 
-	request := Request{endpoint.method, endpoint.url, headers, queryParameters}
-	err = MakeRequest(request, &onlineUsersResponse)
+	for _, batch := range chunkIDs(userIDs) {
+		queryParameters := map[string][]string{}
+		queryParameters["first"] = []string{strconv.Itoa(maxPageSize)}
+		queryParameters["user_id"] = batch
+
+		var batchResponse models.OnlineUsersResponse
+		request := Request{endpoint.method, t.baseURL + endpoint.path, headers, queryParameters}
+		err = t.makeAuthorizedRequest(ctx, request, appToken, &batchResponse)
+		if err != nil {
+			return onlineUsersResponse, err
+		}
+		onlineUsersResponse.Data = append(onlineUsersResponse.Data, batchResponse.Data...)
+	}
 
-	return onlineUsersResponse, err
+	return onlineUsersResponse, nil
 }
 
-// FetchGames calls the Twitch API to get information on games
-func (t *TwitchService) FetchGames(onlineUsers models.OnlineUsersResponse) ([]models.OnlineStreamer, error) {
-	var gamesResponse models.GamesResponse
-	var endpoint = endpoints["TWITCH_GAMES"]
+// FetchLiveLogins reports which of the streamers, by login name, are live
+func (t *TwitchService) FetchLiveLogins(ctx context.Context, logins []string) (map[string]bool, error) {
+	var follows models.TwitchFollowsResponse
+	missing := []string{}
+	for _, login := range logins {
+		login = strings.ToLower(login)
+		if id, ok := t.loginCache.get(login); ok {
+			follows.Data = append(follows.Data, models.FollowInfo{ToID: id.(string), ToName: login})
+		} else {
+			missing = append(missing, login)
+		}
+	}
+	if len(missing) > 0 {
+		usersResponse, err := t.fetchUsers(ctx, "login", missing)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range usersResponse.Data {
+			t.loginCache.set(user.Login, user.ID)
+			follows.Data = append(follows.Data, models.FollowInfo{ToID: user.ID, ToName: user.Login})
+		}
+	}
 
-	headers := map[string]string{}
-	t.appendCommonHeaders(headers)
-	err := t.appendTwitchAuthHeader(headers)
+	live := make(map[string]bool)
+	if len(follows.Data) == 0 {
+		return live, nil
+	}
+	onlineUsersResponse, err := t.FetchTwitchStreamersStatus(ctx, follows)
 	if err != nil {
-		return []models.OnlineStreamer{}, err
+		return nil, err
+	}
+
+	idToLoginMap := make(map[string]string)
+	for _, follow := range follows.Data {
+		idToLoginMap[follow.ToID] = follow.ToName
+	}
+	for _, user := range onlineUsersResponse.Data {
+		live[idToLoginMap[user.UserID]] = true
 	}
+	return live, nil
+}
 
+// FetchGames calls the Twitch API to get game names and profile images for the online streamers.
+// Both lookups run at once, and if either fails the streamers are returned without that information.
+func (t *TwitchService) FetchGames(ctx context.Context, onlineUsers models.OnlineUsersResponse) ([]models.OnlineStreamer, error) {
 	gamesMap := make(map[string]bool)
+	gameIDs := []string{}
 	for _, user := range onlineUsers.Data {
-		gamesMap[user.GameID] = true
+		if user.GameID != "" && !gamesMap[user.GameID] {
+			gamesMap[user.GameID] = true
+			gameIDs = append(gameIDs, user.GameID)
+		}
 	}
 
-	queryParameters := map[string][]string{}
-	queryParameters["first"] = []string{"100"}
-	queryParameters["id"] = []string{}
+	userIDs := make([]string, 0, len(onlineUsers.Data))
 	for _, user := range onlineUsers.Data {
-		queryParameters["id"] = append(queryParameters["id"], user.GameID)
+		userIDs = append(userIDs, user.UserID)
 	}
 
-	request := Request{endpoint.method, endpoint.url, headers, queryParameters}
-	err = MakeRequest(request, &gamesResponse)
-	if err != nil {
-		return []models.OnlineStreamer{}, err
+	var gameIDToNameMap, streamerIDToThumbnailMap map[string]string
+	var gamesError, usersError error
+
+	var wait sync.WaitGroup
+	wait.Add(2)
+	go func() {
+		defer wait.Done()
+		gameIDToNameMap, gamesError = t.gameNames(ctx, gameIDs)
+	}()
+	go func() {
+		defer wait.Done()
+		streamerIDToThumbnailMap, usersError = t.profileImages(ctx, userIDs)
+	}()
+	wait.Wait()
+
+	if ctx.Err() != nil {
+		return []models.OnlineStreamer{}, ctx.Err()
+	}
+	if gamesError != nil {
+		fmt.Println("Error fetching games, continuing without game names: ", gamesError)
+	}
+	if usersError != nil {
+		fmt.Println("Error fetching users, continuing without profile images: ", usersError)
 	}
 
-	usersResponse, err := t.FetchUsers(onlineUsers)
-	if err != nil {
-		return []models.OnlineStreamer{}, err
+	return onlineUsers.MakeOnlineStreamers(gameIDToNameMap, streamerIDToThumbnailMap), nil
+}
+
+// gameNames maps game IDs to names, only looking up the games that aren't cached.
+// The names found before an error are still returned.
+func (t *TwitchService) gameNames(ctx context.Context, gameIDs []string) (map[string]string, error) {
+	gameIDToNameMap := make(map[string]string)
+	missing := []string{}
+	for _, id := range gameIDs {
+		if name, ok := t.gameCache.get(id); ok {
+			gameIDToNameMap[id] = name.(string)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return gameIDToNameMap, nil
+	}
+
+	gamesResponse, err := t.fetchGamesByID(ctx, missing)
+	for _, game := range gamesResponse.Data {
+		t.gameCache.set(game.ID, game.Name)
+		gameIDToNameMap[game.ID] = game.Name
 	}
+	return gameIDToNameMap, err
+}
 
+// profileImages maps user IDs to profile image URLs, only looking up the users that aren't cached.
+// The images found before an error are still returned.
+func (t *TwitchService) profileImages(ctx context.Context, userIDs []string) (map[string]string, error) {
 	streamerIDToThumbnailMap := make(map[string]string)
+	missing := []string{}
+	for _, id := range userIDs {
+		if imageURL, ok := t.userCache.get(id); ok {
+			streamerIDToThumbnailMap[id] = imageURL.(string)
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return streamerIDToThumbnailMap, nil
+	}
+
+	usersResponse, err := t.fetchUsers(ctx, "id", missing)
 	for _, user := range usersResponse.Data {
+		t.userCache.set(user.ID, user.ProfileImageURL)
 		streamerIDToThumbnailMap[user.ID] = user.ProfileImageURL
 	}
+	return streamerIDToThumbnailMap, err
+}
 
-	gameIDToNameMap := make(map[string]string)
-	for _, game := range gamesResponse.Data {
-		gameIDToNameMap[game.ID] = game.Name
+// fetchGamesByID looks up games in concurrent batches, giving up on the rest if one batch fails
+func (t *TwitchService) fetchGamesByID(ctx context.Context, gameIDs []string) (models.GamesResponse, error) {
+	var gamesResponse models.GamesResponse
+	var endpoint = endpoints["TWITCH_GAMES"]
+
+	headers := map[string]string{}
+	t.appendCommonHeaders(headers)
+	err := t.appendTwitchAuthHeader(ctx, headers)
+	if err != nil {
+		return gamesResponse, err
 	}
 
-	return onlineUsers.MakeOnlineStreamers(gameIDToNameMap, streamerIDToThumbnailMap), nil
+	batches := chunkIDs(gameIDs)
+	batchResponses := make([]models.GamesResponse, len(batches))
+	group, ctx := newGroup(ctx)
+	for i, batch := range batches {
+		i, batch := i, batch
+		group.Go(func() error {
+			queryParameters := map[string][]string{}
+			queryParameters["first"] = []string{strconv.Itoa(maxPageSize)}
+			queryParameters["id"] = batch
+
+			request := Request{endpoint.method, t.baseURL + endpoint.path, headers, queryParameters}
+			return t.makeAuthorizedRequest(ctx, request, appToken, &batchResponses[i])
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return gamesResponse, err
+	}
+
+	for _, batchResponse := range batchResponses {
+		gamesResponse.Data = append(gamesResponse.Data, batchResponse.Data...)
+	}
+	return gamesResponse, nil
+}
+
+// FetchUsers calls the Twitch API to get detailed user information, looking up batches concurrently
+func (t *TwitchService) FetchUsers(ctx context.Context, onlineUsers models.OnlineUsersResponse) (models.UsersResponse, error) {
+	userIDs := make([]string, 0, len(onlineUsers.Data))
+	for _, user := range onlineUsers.Data {
+		userIDs = append(userIDs, user.UserID)
+	}
+	return t.fetchUsers(ctx, "id", userIDs)
 }
 
-// FetchUsers calls the Twitch API to get detailed user information
-func (t *TwitchService) FetchUsers(onlineUsers models.OnlineUsersResponse) (models.UsersResponse, error) {
+// fetchUsers looks up users by id or login in concurrent batches, giving up on the rest if one batch fails
+func (t *TwitchService) fetchUsers(ctx context.Context, key string, values []string) (models.UsersResponse, error) {
 	var usersResponse models.UsersResponse
 	var endpoint = endpoints["TWITCH_USERS"]
 
 	headers := map[string]string{}
 	t.appendCommonHeaders(headers)
-	err := t.appendTwitchAuthHeader(headers)
+	err := t.appendTwitchAuthHeader(ctx, headers)
 	if err != nil {
 		return usersResponse, err
 	}
 
-	queryParameters := map[string][]string{}
-	queryParameters["first"] = []string{"100"}
-	queryParameters["id"] = []string{}
-	for _, user := range onlineUsers.Data {
-		queryParameters["id"] = append(queryParameters["id"], user.UserID)
+	batches := chunkIDs(values)
+	batchResponses := make([]models.UsersResponse, len(batches))
+	group, ctx := newGroup(ctx)
+	for i, batch := range batches {
+		i, batch := i, batch
+		group.Go(func() error {
+			queryParameters := map[string][]string{}
+			queryParameters["first"] = []string{strconv.Itoa(maxPageSize)}
+			queryParameters[key] = batch
+
+			request := Request{endpoint.method, t.baseURL + endpoint.path, headers, queryParameters}
+			return t.makeAuthorizedRequest(ctx, request, appToken, &batchResponses[i])
+		})
 	}
-
-	request := Request{endpoint.method, endpoint.url, headers, queryParameters}
-	err = MakeRequest(request, &usersResponse)
-	if err != nil {
+	if err := group.Wait(); err != nil {
 		return usersResponse, err
 	}
 
+	for _, batchResponse := range batchResponses {
+		usersResponse.Data = append(usersResponse.Data, batchResponse.Data...)
+	}
 	return usersResponse, nil
 }
 
-func (t *TwitchService) appendTwitchAuthHeader(headers map[string]string) error {
-	token, authError := t.authManager.GetToken()
+// chunkIDs splits ids into batches small enough for a single Helix lookup
+func chunkIDs(ids []string) [][]string {
+	batches := [][]string{}
+	for len(ids) > maxPageSize {
+		batches = append(batches, ids[:maxPageSize])
+		ids = ids[maxPageSize:]
+	}
+	if len(ids) > 0 {
+		batches = append(batches, ids)
+	}
+	return batches
+}
+
+// tokenKind is the kind of access token a request is authorized with
+type tokenKind int
+
+const (
+	appToken tokenKind = iota
+	userToken
+)
+
+// makeAuthorizedRequest makes a request, and if Twitch rejects its token, replays it once
+// with a new token of the same kind. The kind is that of the token already in the request's Authorization header.
+func (t *TwitchService) makeAuthorizedRequest(ctx context.Context, request Request, kind tokenKind, responseObject interface{}) error {
+	err := t.client.MakeRequest(ctx, request, responseObject)
+	statusError, ok := err.(*StatusError)
+	if !ok || statusError.StatusCode != http.StatusUnauthorized {
+		return err
+	}
+
+	fmt.Println("Twitch rejected the access token, fetching a new one")
+	token := strings.TrimPrefix(request.headers["Authorization"], "Bearer ")
+	// Concurrent lookups share their headers, so the new token goes in a copy
+	headers := make(map[string]string, len(request.headers))
+	for key, value := range request.headers {
+		headers[key] = value
+	}
+	request.headers = headers
+	if kind == userToken {
+		t.authManager.InvalidateUserToken(token)
+		err = t.appendUserAuthHeader(ctx, request.headers)
+	} else {
+		t.authManager.InvalidateAppToken(token)
+		err = t.appendTwitchAuthHeader(ctx, request.headers)
+	}
+	if err != nil {
+		return err
+	}
+	return t.client.MakeRequest(ctx, request, responseObject)
+}
+
+func (t *TwitchService) appendTwitchAuthHeader(ctx context.Context, headers map[string]string) error {
+	token, authError := t.authManager.GetToken(ctx)
+	if authError == nil {
+		headers["Authorization"] = "Bearer " + token
+	}
+	return authError
+}
+
+func (t *TwitchService) appendUserAuthHeader(ctx context.Context, headers map[string]string) error {
+	token, authError := t.authManager.GetUserToken(ctx)
+	if authError == auth.ErrNoUserToken {
+		// Twitch revoked the login, so the user has to log in again
+		return ErrNotLoggedIn
+	}
 	if authError == nil {
 		headers["Authorization"] = "Bearer " + token
 	}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+func TestFetchTwitchStreamersStatusBatchesIDs(t *testing.T) {
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{
+		"/users/follows": pagedHandler(makeItems(250, func(id string) map[string]interface{} {
+			return map[string]interface{}{"to_id": id, "to_name": "User" + id}
+		}), 100),
+		"/streams": liveStreamsHandler,
+	})
+	twitchService := helix.service(false)
+
+	follows, err := twitchService.FetchTwitchFollows(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(follows.Data) != 250 {
+		t.Fatalf("got %d follows, want 250", len(follows.Data))
+	}
+
+	onlineUsers, err := twitchService.FetchTwitchStreamersStatus(context.Background(), follows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(onlineUsers.Data) != 250 {
+		t.Errorf("got %d streams, want 250", len(onlineUsers.Data))
+	}
+
+	requests := helix.received("/streams")
+	if len(requests) != 3 {
+		t.Fatalf("made %d stream lookups, want 3", len(requests))
+	}
+	for i, want := range []int{100, 100, 50} {
+		query := requests[i].URL.Query()
+		if got := len(query["user_id"]); got != want {
+			t.Errorf("lookup %d had %d IDs, want %d", i, got, want)
+		}
+		if requests[i].Header.Get("Authorization") != "Bearer app-token" || requests[i].Header.Get("Client-ID") != "client-id" {
+			t.Errorf("lookup %d had headers %v", i, requests[i].Header)
+		}
+	}
+}
+
+func TestFetchTwitchFollowsFollowsCursor(t *testing.T) {
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{
+		"/channels/followed": pagedHandler(makeItems(230, func(id string) map[string]interface{} {
+			return map[string]interface{}{"broadcaster_id": id, "broadcaster_login": "user" + id, "broadcaster_name": "User" + id}
+		}), 100),
+	})
+
+	follows, err := helix.service(true).FetchTwitchFollows(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(follows.Data) != 230 || follows.Data[0].ToID != "1000" || follows.Data[229].ToID != "1229" {
+		t.Fatalf("got %d follows from %+v to %+v", len(follows.Data), follows.Data[0], follows.Data[len(follows.Data)-1])
+	}
+
+	requests := helix.received("/channels/followed")
+	if len(requests) != 3 {
+		t.Fatalf("made %d requests, want 3", len(requests))
+	}
+	for i, cursor := range []string{"", "100", "200"} {
+		query := requests[i].URL.Query()
+		if query.Get("after") != cursor || query.Get("user_id") != "1" || query.Get("first") != "100" {
+			t.Errorf("request %d had query %v", i, query)
+		}
+		if requests[i].Header.Get("Authorization") != "Bearer user-token" {
+			t.Errorf("request %d had Authorization %q", i, requests[i].Header.Get("Authorization"))
+		}
+	}
+}
+
+func TestFetchFollowedStreamsFollowsCursor(t *testing.T) {
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{
+		"/streams/followed": pagedHandler(makeItems(150, stream), 100),
+	})
+
+	onlineUsers, err := helix.service(true).FetchFollowedStreams(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(onlineUsers.Data) != 150 {
+		t.Errorf("got %d streams, want 150", len(onlineUsers.Data))
+	}
+	if onlineUsers.Data[0].UserLogin != "user1000" {
+		t.Errorf("first stream is %+v", onlineUsers.Data[0])
+	}
+	if requests := helix.received("/streams/followed"); len(requests) != 2 {
+		t.Errorf("made %d requests, want 2", len(requests))
+	}
+	if requests := helix.received("/streams"); len(requests) != 0 {
+		t.Errorf("made %d stream lookups with a user token, want 0", len(requests))
+	}
+}
+
+func TestFetchFollowedStreamsWithoutUserToken(t *testing.T) {
+	helix := newFakeHelix(t, map[string]http.HandlerFunc{
+		"/users/follows": pagedHandler(makeItems(120, func(id string) map[string]interface{} {
+			return map[string]interface{}{"to_id": id, "to_name": "User" + id}
+		}), 100),
+		"/streams": liveStreamsHandler,
+	})
+
+	onlineUsers, err := helix.service(false).FetchFollowedStreams(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(onlineUsers.Data) != 120 {
+		t.Errorf("got %d streams, want 120", len(onlineUsers.Data))
+	}
+	if requests := helix.received("/users/follows"); len(requests) != 2 || requests[0].URL.Query().Get("from_id") != "1" {
+		t.Errorf("made %d follows requests", len(requests))
+	}
+	if requests := helix.received("/streams"); len(requests) != 2 {
+		t.Errorf("made %d stream lookups, want 2", len(requests))
+	}
+}
+
+func TestChunkIDs(t *testing.T) {
+	ids := make([]string, 201)
+	for i, want := range map[int][]int{0: {}, 1: {1}, 100: {100}, 101: {100, 1}, 201: {100, 100, 1}} {
+		batches := chunkIDs(ids[:i])
+		if len(batches) != len(want) {
+			t.Errorf("chunkIDs(%d IDs) made %d batches, want %d", i, len(batches), len(want))
+			continue
+		}
+		for j, batch := range batches {
+			if len(batch) != want[j] {
+				t.Errorf("chunkIDs(%d IDs) batch %d has %d IDs, want %d", i, j, len(batch), want[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,9 @@
+// Package static holds the stylesheet and images of the GUI, embedded in the binary
+package static
+
+import "embed"
+
+// Files are the static assets, served under /static/
+//
+//go:embed *.css *.ico *.png
+var Files embed.FS
@@ -0,0 +1,161 @@
+package streams
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultGQLURL = "https://gql.twitch.tv/gql"
+const defaultUsherURL = "https://usher.ttvnw.net"
+
+// Client-ID of the Twitch web player, GQL refuses playback tokens for other clients
+const webPlayerClientID = "kimne78kx3ncx6brgo4mv6wki5h1ko"
+
+const playbackAccessTokenQuery = `query PlaybackAccessToken($login: String!, $isLive: Boolean!, $vodID: ID!, $isVod: Boolean!, $playerType: String!) {
+	streamPlaybackAccessToken(channelName: $login, params: {platform: "web", playerBackend: "mediaplayer", playerType: $playerType}) @include(if: $isLive) {
+		value
+		signature
+	}
+	videoPlaybackAccessToken(id: $vodID, params: {platform: "web", playerBackend: "mediaplayer", playerType: $playerType}) @include(if: $isVod) {
+		value
+		signature
+	}
+}`
+
+type playbackAccessToken struct {
+	Value     string `json:"value"`
+	Signature string `json:"signature"`
+}
+
+type gqlRequest struct {
+	OperationName string                 `json:"operationName"`
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+type playbackAccessTokenResponse struct {
+	Data struct {
+		StreamPlaybackAccessToken *playbackAccessToken `json:"streamPlaybackAccessToken"`
+		VideoPlaybackAccessToken  *playbackAccessToken `json:"videoPlaybackAccessToken"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// NativeResolver resolves streams by requesting a playback access token and usher playlist directly from Twitch
+type NativeResolver struct {
+	client   *http.Client
+	gqlURL   string
+	usherURL string
+}
+
+// NewNativeResolver creates a new NativeResolver object
+func NewNativeResolver() *NativeResolver {
+	return NewNativeResolverWithURLs(defaultGQLURL, defaultUsherURL)
+}
+
+// NewNativeResolverWithURLs creates a NativeResolver that talks to the given GQL and usher servers
+func NewNativeResolverWithURLs(gqlURL string, usherURL string) *NativeResolver {
+	resolver := NativeResolver{}
+	resolver.client = &http.Client{Timeout: 15 * time.Second}
+	resolver.gqlURL = gqlURL
+	resolver.usherURL = strings.TrimSuffix(usherURL, "/")
+	return &resolver
+}
+
+// Resolve fetches the master playlist of a live channel or VOD and picks the requested quality from its variants
+func (n *NativeResolver) Resolve(request Request) (Result, error) {
+	token, err := n.fetchAccessToken(request)
+	if err != nil {
+		return Result{}, err
+	}
+
+	queryParameters := url.Values{}
+	queryParameters.Set("token", token.Value)
+	queryParameters.Set("sig", token.Signature)
+	queryParameters.Set("allow_source", "true")
+	queryParameters.Set("allow_audio_only", "true")
+	queryParameters.Set("fast_bread", "true")
+	queryParameters.Set("player", "twitchweb")
+	queryParameters.Set("p", strconv.Itoa(rand.Intn(1000000)))
+	playlistURL := n.usherURL + "/api/channel/hls/" + url.PathEscape(strings.ToLower(request.Channel)) + ".m3u8?" + queryParameters.Encode()
+	if request.VideoID != "" {
+		playlistURL = n.usherURL + "/vod/" + url.PathEscape(request.VideoID) + ".m3u8?" + queryParameters.Encode()
+	}
+
+	res, err := n.client.Get(playlistURL)
+	if err != nil {
+		return Result{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return Result{}, errors.New(request.String() + " is not currently available")
+	}
+	if res.StatusCode != http.StatusOK {
+		return Result{}, errors.New("Error fetching the master playlist, got status code " + strconv.Itoa(res.StatusCode))
+	}
+
+	variants, err := ParseMasterPlaylist(res.Body)
+	if err != nil {
+		return Result{}, err
+	}
+	return makeResult(variants, request, nil)
+}
+
+func (n *NativeResolver) fetchAccessToken(request Request) (playbackAccessToken, error) {
+	body, _ := json.Marshal(gqlRequest{
+		OperationName: "PlaybackAccessToken",
+		Query:         playbackAccessTokenQuery,
+		Variables: map[string]interface{}{
+			"login":      strings.ToLower(request.Channel),
+			"isLive":     request.VideoID == "",
+			"vodID":      request.VideoID,
+			"isVod":      request.VideoID != "",
+			"playerType": "embed",
+		},
+	})
+
+	req, _ := http.NewRequest("POST", n.gqlURL, bytes.NewReader(body))
+	req.Header.Set("Client-ID", webPlayerClientID)
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := n.client.Do(req)
+	if err != nil {
+		return playbackAccessToken{}, err
+	}
+	defer res.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return playbackAccessToken{}, errors.New("Error reading access token response")
+	}
+	if res.StatusCode != http.StatusOK {
+		return playbackAccessToken{}, errors.New("Error fetching access token, got status code " + strconv.Itoa(res.StatusCode) + " " + string(responseBody))
+	}
+
+	var tokenResponse playbackAccessTokenResponse
+	if err := json.Unmarshal(responseBody, &tokenResponse); err != nil {
+		return playbackAccessToken{}, errors.New("Error parsing access token JSON")
+	}
+	if len(tokenResponse.Errors) > 0 {
+		return playbackAccessToken{}, errors.New("Error fetching access token: " + tokenResponse.Errors[0].Message)
+	}
+	token := tokenResponse.Data.StreamPlaybackAccessToken
+	if request.VideoID != "" {
+		token = tokenResponse.Data.VideoPlaybackAccessToken
+	}
+	if token == nil {
+		return playbackAccessToken{}, errors.New("No access token returned for " + request.String())
+	}
+	return *token, nil
+}
@@ -0,0 +1,107 @@
+package streams
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// fakeTwitch serves playback access tokens and master playlists the way GQL and usher do
+func fakeTwitch(t *testing.T, gqlResponse string) (*httptest.Server, *httptest.Server) {
+	gql := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.Header.Get("Client-ID") != webPlayerClientID {
+			t.Errorf("unexpected GQL request %s with Client-ID %q", r.Method, r.Header.Get("Client-ID"))
+		}
+		var request gqlRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+		if request.OperationName != "PlaybackAccessToken" {
+			t.Errorf("operationName = %s", request.OperationName)
+		}
+		w.Write([]byte(gqlResponse))
+	}))
+	t.Cleanup(gql.Close)
+
+	usher := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("token") != "token-value" || query.Get("sig") != "token-signature" {
+			t.Errorf("usher got token %q and sig %q", query.Get("token"), query.Get("sig"))
+		}
+		switch r.URL.Path {
+		case "/api/channel/hls/somestreamer.m3u8", "/vod/123456.m3u8":
+			http.ServeFile(w, r, "testdata/master.m3u8")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(usher.Close)
+	return gql, usher
+}
+
+func TestNativeResolver(t *testing.T) {
+	tests := []struct {
+		name    string
+		gql     string
+		request Request
+		wantURL string
+		wantErr string
+	}{
+		{
+			name:    "live channel",
+			gql:     `{"data":{"streamPlaybackAccessToken":{"value":"token-value","signature":"token-signature"}}}`,
+			request: Request{Channel: "SomeStreamer", Quality: "720p30"},
+			wantURL: "https://video-weaver.sea02.hls.ttvnw.net/v1/playlist/720p30.m3u8",
+		},
+		{
+			name:    "vod",
+			gql:     `{"data":{"videoPlaybackAccessToken":{"value":"token-value","signature":"token-signature"}}}`,
+			request: Request{VideoID: "123456", Quality: "best"},
+			wantURL: "https://video-weaver.sea02.hls.ttvnw.net/v1/playlist/chunked.m3u8",
+		},
+		{
+			name:    "offline channel",
+			gql:     `{"data":{"streamPlaybackAccessToken":{"value":"token-value","signature":"token-signature"}}}`,
+			request: Request{Channel: "offline", Quality: "best"},
+			wantErr: "offline is not currently available",
+		},
+		{
+			name:    "gql error",
+			gql:     `{"errors":[{"message":"service timeout"}]}`,
+			request: Request{Channel: "somestreamer", Quality: "best"},
+			wantErr: "Error fetching access token: service timeout",
+		},
+		{
+			name:    "missing token",
+			gql:     `{"data":{"streamPlaybackAccessToken":null}}`,
+			request: Request{Channel: "somestreamer", Quality: "best"},
+			wantErr: "No access token returned for somestreamer",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			gql, usher := fakeTwitch(t, test.gql)
+			resolver := NewNativeResolverWithURLs(gql.URL, usher.URL+"/")
+
+			result, err := resolver.Resolve(test.request)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.URL != test.wantURL {
+				t.Errorf("URL = %s, want %s", result.URL, test.wantURL)
+			}
+			if len(result.Variants) != 9 {
+				t.Errorf("got %d variants, want 9", len(result.Variants))
+			}
+		})
+	}
+}
@@ -0,0 +1,136 @@
+package streams
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const audioOnlyName = "audio_only"
+
+// ParseMasterPlaylist reads an HLS master playlist into variants named the way streamlink names them
+func ParseMasterPlaylist(reader io.Reader) (map[string]Variant, error) {
+	mediaNames := make(map[string]string)
+	variants := make(map[string]Variant)
+
+	var pending map[string]string
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "#EXT-X-MEDIA:"):
+			attributes := parseAttributes(strings.TrimPrefix(line, "#EXT-X-MEDIA:"))
+			if attributes["TYPE"] == "VIDEO" {
+				mediaNames[attributes["GROUP-ID"]] = attributes["NAME"]
+			}
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			pending = parseAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+		case strings.HasPrefix(line, "#"):
+			continue
+		case pending != nil:
+			variant := makeVariant(pending, mediaNames[pending["VIDEO"]], line)
+			variants[variant.Name] = variant
+			pending = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(variants) == 0 {
+		return nil, errors.New("No streams found in the master playlist")
+	}
+	addBestAndWorst(variants)
+	return variants, nil
+}
+
+func makeVariant(attributes map[string]string, mediaName string, url string) Variant {
+	variant := Variant{URL: url, Resolution: attributes["RESOLUTION"]}
+	variant.Bandwidth, _ = strconv.Atoi(attributes["BANDWIDTH"])
+	variant.FrameRate, _ = strconv.ParseFloat(attributes["FRAME-RATE"], 64)
+
+	name := strings.TrimSuffix(mediaName, " (source)")
+	if name == "" || strings.Contains(name, " ") {
+		name = nameFromResolution(variant)
+	}
+	if name == "audio_only" || attributes["VIDEO"] == audioOnlyName {
+		name = audioOnlyName
+	}
+	variant.Name = name
+	return variant
+}
+
+// nameFromResolution builds a name such as 720p60 when the playlist doesn't name a variant
+func nameFromResolution(variant Variant) string {
+	dimensions := strings.Split(variant.Resolution, "x")
+	if len(dimensions) != 2 {
+		return audioOnlyName
+	}
+
+	name := dimensions[1] + "p"
+	if variant.FrameRate > 30 {
+		name += strconv.Itoa(int(math.Round(variant.FrameRate)))
+	}
+	return name
+}
+
+// addBestAndWorst adds the best and worst aliases streamlink provides, audio only streams are never picked for either
+func addBestAndWorst(variants map[string]Variant) {
+	var best, worst *Variant
+	for _, variant := range variants {
+		if variant.Name == audioOnlyName {
+			continue
+		}
+		variant := variant
+		if best == nil || variant.Bandwidth > best.Bandwidth {
+			best = &variant
+		}
+		if worst == nil || variant.Bandwidth < worst.Bandwidth {
+			worst = &variant
+		}
+	}
+
+	if best == nil {
+		return
+	}
+	variants["best"] = *best
+	variants["worst"] = *worst
+}
+
+// parseAttributes splits an HLS attribute list, respecting commas inside quoted values
+func parseAttributes(list string) map[string]string {
+	attributes := make(map[string]string)
+	for len(list) > 0 {
+		separator := strings.IndexByte(list, '=')
+		if separator < 0 {
+			break
+		}
+		key := strings.TrimSpace(list[:separator])
+		list = list[separator+1:]
+
+		var value string
+		if strings.HasPrefix(list, "\"") {
+			end := strings.IndexByte(list[1:], '"')
+			if end < 0 {
+				value, list = list[1:], ""
+			} else {
+				value, list = list[1:end+1], list[end+2:]
+			}
+		} else {
+			end := strings.IndexByte(list, ',')
+			if end < 0 {
+				value, list = list, ""
+			} else {
+				value, list = list[:end], list[end:]
+			}
+		}
+		attributes[key] = value
+		list = strings.TrimPrefix(list, ",")
+	}
+	return attributes
+}
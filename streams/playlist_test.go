@@ -0,0 +1,70 @@
+package streams
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseMasterPlaylist(t *testing.T) {
+	file, err := os.Open("testdata/master.m3u8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	variants, err := ParseMasterPlaylist(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := make([]string, 0, len(variants))
+	for name := range variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	want := "1080p60,160p,360p,480p,720p,720p60,audio_only,best,worst"
+	if got := strings.Join(names, ","); got != want {
+		t.Fatalf("variants = %s, want %s", got, want)
+	}
+
+	source := variants["1080p60"]
+	if source.Resolution != "1920x1080" || source.FrameRate != 60 || source.Bandwidth != 8534030 ||
+		source.URL != "https://video-weaver.sea02.hls.ttvnw.net/v1/playlist/chunked.m3u8" {
+		t.Errorf("1080p60 = %+v", source)
+	}
+	if variants["best"].URL != source.URL {
+		t.Errorf("best = %s, want the source variant", variants["best"].URL)
+	}
+	if variants["worst"].URL != variants["160p"].URL {
+		t.Errorf("worst = %s, want the 160p variant", variants["worst"].URL)
+	}
+	if audio := variants["audio_only"]; audio.Resolution != "" || !strings.HasSuffix(audio.URL, "/audio_only.m3u8") {
+		t.Errorf("audio_only = %+v", audio)
+	}
+}
+
+func TestParseMasterPlaylistWithoutStreams(t *testing.T) {
+	if _, err := ParseMasterPlaylist(strings.NewReader("#EXTM3U\n")); err == nil {
+		t.Error("expected an error for a playlist without streams")
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	attributes := parseAttributes(`BANDWIDTH=3422999,CODECS="avc1.4D401F,mp4a.40.2",VIDEO="720p60",FRAME-RATE=60.000`)
+	want := map[string]string{
+		"BANDWIDTH":  "3422999",
+		"CODECS":     "avc1.4D401F,mp4a.40.2",
+		"VIDEO":      "720p60",
+		"FRAME-RATE": "60.000",
+	}
+	if len(attributes) != len(want) {
+		t.Errorf("attributes = %v, want %v", attributes, want)
+	}
+	for key, value := range want {
+		if attributes[key] != value {
+			t.Errorf("%s = %q, want %q", key, attributes[key], value)
+		}
+	}
+}
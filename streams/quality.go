@@ -0,0 +1,151 @@
+package streams
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Quality is a parsed stream quality name such as 720p60, best or audio_only.
+// FrameRate is zero when the name doesn't include one.
+type Quality struct {
+	Height    int
+	FrameRate int
+	Alias     string
+}
+
+// QualityOptions tune how a variant is picked beneath the maximum quality
+type QualityOptions struct {
+	// Prefer60FPS picks a 60fps variant over a higher resolution one at a lower framerate
+	Prefer60FPS bool
+	// AudioOnly picks the audio_only variant regardless of the maximum
+	AudioOnly bool
+	// Minimum is the lowest quality that is acceptable, for example 480p
+	Minimum string
+}
+
+// Aliases streamlink gives to particular variants
+const (
+	BestQuality      = "best"
+	WorstQuality     = "worst"
+	AudioOnlyQuality = audioOnlyName
+)
+
+// Twitch names variants like 720p60, occasionally with an _alt suffix for a second encoding
+var qualityPattern = regexp.MustCompile(`^(\d+)p(\d+)?(?:_alt\d*)?$`)
+
+// Variants without a framerate in their name run at 30fps
+const defaultFrameRate = 30
+
+// ParseQuality parses a quality name, returning false if it isn't one
+func ParseQuality(name string) (Quality, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch name {
+	case BestQuality, WorstQuality, AudioOnlyQuality:
+		return Quality{Alias: name}, true
+	case "source":
+		return Quality{Alias: BestQuality}, true
+	}
+
+	match := qualityPattern.FindStringSubmatch(name)
+	if match == nil {
+		return Quality{}, false
+	}
+	height, _ := strconv.Atoi(match[1])
+	frameRate, _ := strconv.Atoi(match[2])
+	return Quality{Height: height, FrameRate: frameRate}, true
+}
+
+func (q Quality) frameRate() int {
+	if q.FrameRate == 0 {
+		return defaultFrameRate
+	}
+	return q.FrameRate
+}
+
+// atMost reports whether q is no better than ceiling, a ceiling without a framerate allows any framerate
+func (q Quality) atMost(ceiling Quality) bool {
+	if q.Height != ceiling.Height {
+		return q.Height < ceiling.Height
+	}
+	return ceiling.FrameRate == 0 || q.frameRate() <= ceiling.FrameRate
+}
+
+// better reports whether q should be picked over other
+func (q Quality) better(other Quality, prefer60FPS bool) bool {
+	if prefer60FPS && (q.frameRate() >= 60) != (other.frameRate() >= 60) {
+		return q.frameRate() >= 60
+	}
+	if q.Height != other.Height {
+		return q.Height > other.Height
+	}
+	return q.frameRate() > other.frameRate()
+}
+
+// SelectVariant picks the best variant at or below the maximum quality, honouring the options.
+// When nothing fits beneath the maximum the lowest quality video variant is used instead.
+func SelectVariant(variants map[string]Variant, maximum string, options QualityOptions) (Variant, error) {
+	maximum = strings.ToLower(strings.TrimSpace(maximum))
+	ceiling, ok := ParseQuality(maximum)
+	if !ok {
+		return Variant{}, errors.New("Invalid quality " + maximum)
+	}
+
+	// The minimum only applies to video, so it can't turn an audio only request into a video one
+	if options.AudioOnly || ceiling.Alias == AudioOnlyQuality {
+		if variant, ok := variants[AudioOnlyQuality]; ok {
+			return variant, nil
+		}
+		return Variant{}, errors.New("No audio only stream available")
+	}
+	if ceiling.Alias != "" && options.Minimum == "" {
+		if variant, ok := variants[ceiling.Alias]; ok {
+			return variant, nil
+		}
+	}
+
+	var minimum Quality
+	if options.Minimum != "" {
+		minimum, ok = ParseQuality(options.Minimum)
+		if !ok || minimum.Alias != "" {
+			return Variant{}, errors.New("Invalid minimum quality " + options.Minimum)
+		}
+	}
+
+	// Equal qualities are decided by name so 720p60 is picked over 720p60_alt
+	var best, lowest *Variant
+	var bestQuality, lowestQuality Quality
+	var bestName, lowestName string
+	for name, variant := range variants {
+		quality, ok := ParseQuality(name)
+		if !ok || quality.Alias != "" || quality.Height < minimum.Height {
+			continue
+		}
+
+		variant := variant
+		if lowest == nil || lowestQuality.better(quality, false) || !quality.better(lowestQuality, false) && name < lowestName {
+			lowest, lowestQuality, lowestName = &variant, quality, name
+		}
+		if ceiling.Alias == WorstQuality || ceiling.Alias == "" && !quality.atMost(ceiling) {
+			continue
+		}
+		if best == nil || quality.better(bestQuality, options.Prefer60FPS) || !bestQuality.better(quality, options.Prefer60FPS) && name < bestName {
+			best, bestQuality, bestName = &variant, quality, name
+		}
+	}
+
+	if ceiling.Alias == WorstQuality {
+		best = lowest
+	}
+	if best != nil {
+		return *best, nil
+	}
+	if lowest != nil {
+		return *lowest, nil
+	}
+	if options.Minimum != "" {
+		return Variant{}, errors.New("No stream available at or above " + options.Minimum)
+	}
+	return Variant{}, errors.New("Could not find a stream at or below " + maximum)
+}
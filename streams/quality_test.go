@@ -0,0 +1,104 @@
+package streams
+
+import (
+	"strings"
+	"testing"
+)
+
+// makeVariants builds variants named like Twitch names them, with bandwidth increasing in the order given
+func makeVariants(names ...string) map[string]Variant {
+	variants := make(map[string]Variant, len(names))
+	for i, name := range names {
+		variants[name] = Variant{Name: name, URL: "https://example.com/" + name + ".m3u8", Bandwidth: (i + 1) * 1000}
+	}
+	addBestAndWorst(variants)
+	return variants
+}
+
+func TestSelectVariant(t *testing.T) {
+	twitch := makeVariants("160p", "360p", "480p", "720p", "720p60", "1080p60")
+
+	tests := []struct {
+		name     string
+		variants map[string]Variant
+		maximum  string
+		options  QualityOptions
+		want     string
+		wantErr  string
+	}{
+		{name: "exact match", variants: twitch, maximum: "480p", want: "480p"},
+		{name: "maximum without a framerate allows 60fps", variants: makeVariants("480p", "720p60", "1080p60"), maximum: "720p", want: "720p60"},
+		{name: "maximum with a framerate excludes 60fps", variants: makeVariants("480p", "720p60", "1080p60"), maximum: "720p30", want: "480p"},
+		{name: "highest framerate at the maximum height", variants: twitch, maximum: "720p", want: "720p60"},
+		{name: "936p is between 720p and 1080p", variants: makeVariants("720p60", "936p60", "1080p60"), maximum: "1000p", want: "936p60"},
+		{name: "936p exceeds 900p", variants: makeVariants("720p60", "936p60", "1080p60"), maximum: "900p", want: "720p60"},
+		{name: "936p exact", variants: makeVariants("720p60", "936p60", "1080p60"), maximum: "936p60", want: "936p60"},
+		{name: "prefer 60fps over resolution", variants: makeVariants("480p", "720p60", "1080p"), maximum: "1080p", options: QualityOptions{Prefer60FPS: true}, want: "720p60"},
+		{name: "resolution without prefer 60fps", variants: makeVariants("480p", "720p60", "1080p"), maximum: "1080p", want: "1080p"},
+		{name: "prefer 60fps without a 60fps variant", variants: makeVariants("480p", "720p"), maximum: "best", options: QualityOptions{Prefer60FPS: true, Minimum: "160p"}, want: "720p"},
+		{name: "audio only option", variants: makeVariants("480p", "audio_only"), maximum: "best", options: QualityOptions{AudioOnly: true}, want: "audio_only"},
+		{name: "audio only option without audio", variants: makeVariants("480p"), maximum: "best", options: QualityOptions{AudioOnly: true}, wantErr: "No audio only stream available"},
+		{name: "audio only maximum", variants: makeVariants("480p", "audio_only"), maximum: "audio_only", want: "audio_only"},
+		{name: "audio only maximum ignores the minimum", variants: makeVariants("480p", "1080p60", "audio_only"), maximum: "audio_only", options: QualityOptions{Minimum: "480p"}, want: "audio_only"},
+		{name: "minimum above the maximum", variants: twitch, maximum: "360p", options: QualityOptions{Minimum: "480p"}, want: "480p"},
+		{name: "minimum below the maximum", variants: twitch, maximum: "720p", options: QualityOptions{Minimum: "480p"}, want: "720p60"},
+		{name: "minimum with best", variants: twitch, maximum: "best", options: QualityOptions{Minimum: "480p"}, want: "1080p60"},
+		{name: "minimum with worst", variants: twitch, maximum: "worst", options: QualityOptions{Minimum: "480p"}, want: "480p"},
+		{name: "minimum not available", variants: makeVariants("160p", "360p"), maximum: "best", options: QualityOptions{Minimum: "720p"}, wantErr: "No stream available at or above 720p"},
+		{name: "invalid minimum", variants: twitch, maximum: "best", options: QualityOptions{Minimum: "best"}, wantErr: "Invalid minimum quality best"},
+		{name: "alt encoding loses a tie", variants: makeVariants("720p60_alt", "720p60", "1080p60"), maximum: "720p60", want: "720p60"},
+		{name: "alt encoding is a valid quality", variants: makeVariants("480p", "720p60_alt"), maximum: "720p", want: "720p60_alt"},
+		{name: "best alias", variants: twitch, maximum: "best", want: "1080p60"},
+		{name: "source alias", variants: twitch, maximum: "Source", want: "1080p60"},
+		{name: "worst alias", variants: twitch, maximum: "worst", want: "160p"},
+		{name: "best without the alias", variants: map[string]Variant{"480p": {URL: "480p"}, "720p": {URL: "720p"}}, maximum: "best", want: "720p"},
+		{name: "worst without the alias", variants: map[string]Variant{"480p": {URL: "480p"}, "720p": {URL: "720p"}}, maximum: "worst", want: "480p"},
+		{name: "falls back to the lowest variant", variants: makeVariants("audio_only", "360p", "160p"), maximum: "144p", want: "160p"},
+		{name: "only audio", variants: makeVariants("audio_only"), maximum: "720p", wantErr: "Could not find a stream at or below 720p"},
+		{name: "invalid maximum", variants: twitch, maximum: "hd", wantErr: "Invalid quality hd"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			variant, err := SelectVariant(test.variants, test.maximum, test.options)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := test.variants[test.want].URL; variant.URL != want {
+				t.Errorf("picked %s, want %s", variant.URL, want)
+			}
+		})
+	}
+}
+
+func TestParseQuality(t *testing.T) {
+	tests := []struct {
+		name string
+		want Quality
+		ok   bool
+	}{
+		{"720p60", Quality{Height: 720, FrameRate: 60}, true},
+		{"720p", Quality{Height: 720}, true},
+		{" 1080P60 ", Quality{Height: 1080, FrameRate: 60}, true},
+		{"720p60_alt", Quality{Height: 720, FrameRate: 60}, true},
+		{"720p_alt2", Quality{Height: 720}, true},
+		{"best", Quality{Alias: BestQuality}, true},
+		{"source", Quality{Alias: BestQuality}, true},
+		{"worst", Quality{Alias: WorstQuality}, true},
+		{"audio_only", Quality{Alias: AudioOnlyQuality}, true},
+		{"hd", Quality{}, false},
+		{"p60", Quality{}, false},
+	}
+	for _, test := range tests {
+		got, ok := ParseQuality(test.name)
+		if got != test.want || ok != test.ok {
+			t.Errorf("ParseQuality(%q) = %+v, %v, want %+v, %v", test.name, got, ok, test.want, test.ok)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Variant is a single playable quality of a stream
+type Variant struct {
+	Name       string  `json:"name"`
+	URL        string  `json:"url"`
+	Bandwidth  int     `json:"bandwidth"`
+	Resolution string  `json:"resolution"`
+	FrameRate  float64 `json:"frameRate"`
+}
+
+// Request describes a stream to resolve, either a live Channel or a VOD by its VideoID.
+// Quality is the highest quality wanted, see SelectVariant.
+type Request struct {
+	Channel string
+	VideoID string
+	Quality string
+	Options QualityOptions
+}
+
+// Result is a resolved stream, URL and Headers are for the variant picked for the requested quality
+type Result struct {
+	URL      string             `json:"url"`
+	Headers  map[string]string  `json:"headers"`
+	Variants map[string]Variant `json:"variants"`
+}
+
+// StreamResolver finds the playable URL of a Twitch channel or VOD
+type StreamResolver interface {
+	Resolve(request Request) (Result, error)
+}
+
+// Names of the supported StreamResolver backends in configuration.json
+const (
+	NativeResolverName     = "native"
+	StreamlinkResolverName = "streamlink"
+	YtDlpResolverName      = "yt-dlp"
+)
+
+// Resolvers that run an external command give up on it after this long
+const commandTimeout = 30 * time.Second
+
+var videoPattern = regexp.MustCompile(`^(?:(?:https?://)?(?:www\.)?twitch\.tv/)?videos/(\d+)$`)
+var channelPattern = regexp.MustCompile(`^(?:(?:https?://)?(?:www\.)?twitch\.tv/)?(\w+)$`)
+
+// ParseTarget turns a channel name, VOD path such as videos/123, or a full Twitch URL into a Request
+func ParseTarget(target string, quality string, options QualityOptions) (Request, error) {
+	target = strings.TrimSuffix(strings.TrimSpace(target), "/")
+	if match := videoPattern.FindStringSubmatch(target); match != nil {
+		return Request{VideoID: match[1], Quality: quality, Options: options}, nil
+	}
+	if match := channelPattern.FindStringSubmatch(target); match != nil {
+		return Request{Channel: strings.ToLower(match[1]), Quality: quality, Options: options}, nil
+	}
+	return Request{}, errors.New("Invalid stream ID " + target)
+}
+
+// pageURL returns the twitch.tv page of the requested channel or VOD
+func (r Request) pageURL() string {
+	if r.VideoID != "" {
+		return "https://www.twitch.tv/videos/" + url.PathEscape(r.VideoID)
+	}
+	return "https://www.twitch.tv/" + url.PathEscape(r.Channel)
+}
+
+func (r Request) String() string {
+	if r.VideoID != "" {
+		return "video " + r.VideoID
+	}
+	return r.Channel
+}
+
+// NewResolver creates a StreamResolver that tries the named backends in order until one succeeds
+func NewResolver(names []string) (StreamResolver, error) {
+	if len(names) == 0 {
+		names = []string{NativeResolverName}
+	}
+
+	fallbackResolver := FallbackResolver{}
+	for _, name := range names {
+		var resolver StreamResolver
+		switch strings.ToLower(name) {
+		case NativeResolverName:
+			resolver = NewNativeResolver()
+		case StreamlinkResolverName:
+			resolver = NewStreamlinkResolver()
+		case YtDlpResolverName:
+			resolver = NewYtDlpResolver()
+		default:
+			return nil, errors.New("Unknown stream resolver " + name)
+		}
+		fallbackResolver.names = append(fallbackResolver.names, name)
+		fallbackResolver.resolvers = append(fallbackResolver.resolvers, resolver)
+	}
+
+	if len(fallbackResolver.resolvers) == 1 {
+		return fallbackResolver.resolvers[0], nil
+	}
+	return &fallbackResolver, nil
+}
+
+// FallbackResolver tries each of its resolvers in order and returns the first successful result
+type FallbackResolver struct {
+	names     []string
+	resolvers []StreamResolver
+}
+
+// Resolve asks each resolver in turn, returning every error if they all fail
+func (f *FallbackResolver) Resolve(request Request) (Result, error) {
+	var failures []string
+	for i, resolver := range f.resolvers {
+		result, err := resolver.Resolve(request)
+		if err == nil {
+			return result, nil
+		}
+		fmt.Println("Stream resolver "+f.names[i]+" failed, trying the next one: ", err)
+		failures = append(failures, f.names[i]+": "+err.Error())
+	}
+	return Result{}, errors.New("All stream resolvers failed for " + request.String() + " (" + strings.Join(failures, "; ") + ")")
+}
+
+// makeResult builds a Result from the resolved variants
+func makeResult(variants map[string]Variant, request Request, headers map[string]string) (Result, error) {
+	variant, err := SelectVariant(variants, request.Quality, request.Options)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{URL: variant.URL, Headers: headers, Variants: variants}, nil
+}
+
+// runCommand runs an external resolver and returns what it wrote to stdout, killing it once timeout has passed
+func runCommand(timeout time.Duration, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	output, err := exec.CommandContext(ctx, name, args...).Output()
+	if ctx.Err() == context.DeadlineExceeded {
+		return output, errors.New(name + " did not finish within " + timeout.String())
+	}
+	if exitError, ok := err.(*exec.ExitError); ok {
+		message := strings.TrimSpace(string(exitError.Stderr))
+		if message == "" {
+			message = exitError.Error()
+		}
+		return output, errors.New(name + " failed: " + message)
+	}
+	return output, err
+}
@@ -0,0 +1,110 @@
+package streams
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeExecutable puts a shell script named name at the front of the PATH for the rest of the test
+func fakeExecutable(t *testing.T, name string, script string) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+type fakeResolver struct {
+	result Result
+	err    error
+	calls  *[]string
+	name   string
+}
+
+func (f fakeResolver) Resolve(request Request) (Result, error) {
+	*f.calls = append(*f.calls, f.name)
+	return f.result, f.err
+}
+
+func TestFallbackResolver(t *testing.T) {
+	tests := []struct {
+		name      string
+		errors    []error
+		wantURL   string
+		wantCalls string
+		wantErr   string
+	}{
+		{
+			name:      "first succeeds",
+			errors:    []error{nil, nil, nil},
+			wantURL:   "native",
+			wantCalls: "native",
+		},
+		{
+			name:      "falls back in order",
+			errors:    []error{errors.New("GQL is down"), nil, nil},
+			wantURL:   "streamlink",
+			wantCalls: "native,streamlink",
+		},
+		{
+			name:      "all fail",
+			errors:    []error{errors.New("GQL is down"), errors.New("not installed"), errors.New("offline")},
+			wantCalls: "native,streamlink,yt-dlp",
+			wantErr:   "All stream resolvers failed for somestreamer (native: GQL is down; streamlink: not installed; yt-dlp: offline)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls []string
+			resolver := FallbackResolver{names: []string{NativeResolverName, StreamlinkResolverName, YtDlpResolverName}}
+			for i, name := range resolver.names {
+				resolver.resolvers = append(resolver.resolvers, fakeResolver{Result{URL: name}, test.errors[i], &calls, name})
+			}
+
+			result, err := resolver.Resolve(Request{Channel: "somestreamer"})
+			if got := strings.Join(calls, ","); got != test.wantCalls {
+				t.Errorf("called %s, want %s", got, test.wantCalls)
+			}
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Errorf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.URL != test.wantURL {
+				t.Errorf("URL = %s, want %s", result.URL, test.wantURL)
+			}
+		})
+	}
+}
+
+func TestNewResolver(t *testing.T) {
+	if _, err := NewResolver([]string{"native", "vlc"}); err == nil || err.Error() != "Unknown stream resolver vlc" {
+		t.Errorf("error = %v, want an unknown resolver error", err)
+	}
+
+	resolver, err := NewResolver(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := resolver.(*NativeResolver); !ok {
+		t.Errorf("default resolver is %T, want *NativeResolver", resolver)
+	}
+
+	resolver, err = NewResolver([]string{"Streamlink", "yt-dlp"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fallback, ok := resolver.(*FallbackResolver); !ok || strings.Join(fallback.names, ",") != "Streamlink,yt-dlp" {
+		t.Errorf("resolver = %#v, want a FallbackResolver trying streamlink then yt-dlp", resolver)
+	}
+}
@@ -0,0 +1,68 @@
+package streams
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Response object when quality is not specified
+type streamLinkFullResponse struct {
+	Streams map[string]streamLinkStreamInfo `json:"streams"`
+	Plugin  string                          `json:"plugin"`
+	Error   string                          `json:"error"`
+}
+
+type streamLinkStreamInfo struct {
+	Type    string            `json:"type"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+}
+
+// StreamlinkResolver resolves streams by running the streamlink binary from the PATH
+type StreamlinkResolver struct {
+	command string
+	timeout time.Duration
+}
+
+// NewStreamlinkResolver creates a new StreamlinkResolver object
+func NewStreamlinkResolver() *StreamlinkResolver {
+	resolver := StreamlinkResolver{}
+	resolver.command = "streamlink"
+	resolver.timeout = commandTimeout
+	return &resolver
+}
+
+// Resolve runs streamlink in JSON mode and picks the requested quality from the streams it found
+func (s *StreamlinkResolver) Resolve(request Request) (Result, error) {
+	output, streamLinkError := runCommand(s.timeout, s.command, request.pageURL(), "--http-header=Client-ID=jzkbprff40iqj646a697cyrvl0zt2m6", "--player-passthrough=http,hls,rtmp", "-j")
+
+	// In JSON mode streamlink reports errors such as an offline channel on stdout
+	var streamLinkResponse streamLinkFullResponse
+	jsonError := json.Unmarshal(output, &streamLinkResponse)
+	if streamLinkResponse.Error != "" {
+		return Result{}, errors.New("streamlink failed: " + streamLinkResponse.Error)
+	}
+	if streamLinkError != nil {
+		return Result{}, streamLinkError
+	}
+	if jsonError != nil {
+		return Result{}, jsonError
+	}
+
+	variants := make(map[string]Variant, len(streamLinkResponse.Streams))
+	for name, stream := range streamLinkResponse.Streams {
+		variants[name] = Variant{Name: name, URL: stream.URL}
+	}
+
+	result, err := makeResult(variants, request, nil)
+	if err != nil {
+		return Result{}, err
+	}
+	for _, stream := range streamLinkResponse.Streams {
+		if stream.URL == result.URL {
+			result.Headers = stream.Headers
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,99 @@
+package streams
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const streamlinkJSON = `{
+  "plugin": "twitch",
+  "streams": {
+    "audio_only": {"type": "hls", "url": "https://example.com/audio_only.m3u8", "headers": {"User-Agent": "streamlink"}},
+    "480p": {"type": "hls", "url": "https://example.com/480p.m3u8", "headers": {"User-Agent": "streamlink"}},
+    "720p60": {"type": "hls", "url": "https://example.com/720p60.m3u8", "headers": {"User-Agent": "streamlink"}},
+    "1080p60": {"type": "hls", "url": "https://example.com/1080p60.m3u8", "headers": {"User-Agent": "streamlink", "Referer": "https://www.twitch.tv/"}},
+    "worst": {"type": "hls", "url": "https://example.com/480p.m3u8", "headers": {"User-Agent": "streamlink"}},
+    "best": {"type": "hls", "url": "https://example.com/1080p60.m3u8", "headers": {"User-Agent": "streamlink", "Referer": "https://www.twitch.tv/"}}
+  }
+}`
+
+func TestStreamlinkResolver(t *testing.T) {
+	tests := []struct {
+		name        string
+		script      string
+		request     Request
+		wantURL     string
+		wantReferer string
+		wantErr     string
+	}{
+		{
+			name:        "best",
+			script:      `[ "$1" = "https://www.twitch.tv/somestreamer" ] || exit 3` + "\ncat <<'EOF'\n" + streamlinkJSON + "\nEOF\n",
+			request:     Request{Channel: "somestreamer", Quality: "best"},
+			wantURL:     "https://example.com/1080p60.m3u8",
+			wantReferer: "https://www.twitch.tv/",
+		},
+		{
+			name:    "quality below the maximum",
+			script:  "cat <<'EOF'\n" + streamlinkJSON + "\nEOF\n",
+			request: Request{Channel: "somestreamer", Quality: "720p"},
+			wantURL: "https://example.com/720p60.m3u8",
+		},
+		{
+			name:    "vod",
+			script:  `[ "$1" = "https://www.twitch.tv/videos/123" ] || exit 3` + "\ncat <<'EOF'\n" + streamlinkJSON + "\nEOF\n",
+			request: Request{VideoID: "123", Quality: "worst"},
+			wantURL: "https://example.com/480p.m3u8",
+		},
+		{
+			name:    "offline",
+			script:  `echo '{"error": "No playable streams found on this URL: https://www.twitch.tv/somestreamer"}'` + "\nexit 1\n",
+			request: Request{Channel: "somestreamer", Quality: "best"},
+			wantErr: "streamlink failed: No playable streams found on this URL",
+		},
+		{
+			name:    "crash",
+			script:  "echo 'Traceback (most recent call last):' >&2\nexit 1\n",
+			request: Request{Channel: "somestreamer", Quality: "best"},
+			wantErr: "streamlink failed: Traceback (most recent call last):",
+		},
+		{
+			name:    "invalid output",
+			script:  "echo 'not json'\n",
+			request: Request{Channel: "somestreamer", Quality: "best"},
+			wantErr: "invalid character",
+		},
+		{
+			name:    "hangs",
+			script:  "exec sleep 10\n",
+			request: Request{Channel: "somestreamer", Quality: "best"},
+			wantErr: "streamlink did not finish within 200ms",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeExecutable(t, "streamlink", test.script)
+			resolver := NewStreamlinkResolver()
+			resolver.timeout = 200 * time.Millisecond
+
+			result, err := resolver.Resolve(test.request)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.URL != test.wantURL {
+				t.Errorf("URL = %s, want %s", result.URL, test.wantURL)
+			}
+			if result.Headers["Referer"] != test.wantReferer {
+				t.Errorf("Referer = %q, want %q", result.Headers["Referer"], test.wantReferer)
+			}
+		})
+	}
+}
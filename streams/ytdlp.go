@@ -0,0 +1,89 @@
+package streams
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+)
+
+type ytDlpResponse struct {
+	Formats []ytDlpFormat `json:"formats"`
+}
+
+type ytDlpFormat struct {
+	FormatID    string            `json:"format_id"`
+	URL         string            `json:"url"`
+	Protocol    string            `json:"protocol"`
+	Width       int               `json:"width"`
+	Height      int               `json:"height"`
+	FPS         float64           `json:"fps"`
+	TBR         float64           `json:"tbr"`
+	VideoCodec  string            `json:"vcodec"`
+	HTTPHeaders map[string]string `json:"http_headers"`
+}
+
+// YtDlpResolver resolves streams by running the yt-dlp binary from the PATH
+type YtDlpResolver struct {
+	command string
+	timeout time.Duration
+}
+
+// NewYtDlpResolver creates a new YtDlpResolver object
+func NewYtDlpResolver() *YtDlpResolver {
+	resolver := YtDlpResolver{}
+	resolver.command = "yt-dlp"
+	resolver.timeout = commandTimeout
+	return &resolver
+}
+
+// Resolve runs yt-dlp in JSON mode and picks the requested quality from the HLS formats it found
+func (y *YtDlpResolver) Resolve(request Request) (Result, error) {
+	output, ytDlpError := runCommand(y.timeout, y.command, "--dump-single-json", "--no-warnings", request.pageURL())
+	if ytDlpError != nil {
+		return Result{}, ytDlpError
+	}
+
+	var response ytDlpResponse
+	if err := json.Unmarshal(output, &response); err != nil {
+		return Result{}, err
+	}
+
+	variants := make(map[string]Variant)
+	headers := make(map[string]map[string]string)
+	for _, format := range response.Formats {
+		if format.URL == "" || format.Protocol != "m3u8_native" && format.Protocol != "m3u8" {
+			continue
+		}
+
+		variant := Variant{
+			URL:       format.URL,
+			Bandwidth: int(format.TBR * 1000),
+			FrameRate: format.FPS,
+		}
+		if format.VideoCodec == "none" {
+			variant.Name = audioOnlyName
+		} else {
+			variant.Resolution = strconv.Itoa(format.Width) + "x" + strconv.Itoa(format.Height)
+			variant.Name = nameFromResolution(variant)
+		}
+		// The same quality can be listed more than once, such as from two CDNs. Keep the first with the most bandwidth.
+		if existing, ok := variants[variant.Name]; ok && existing.Bandwidth >= variant.Bandwidth {
+			continue
+		}
+		variants[variant.Name] = variant
+		headers[variant.URL] = format.HTTPHeaders
+	}
+
+	if len(variants) == 0 {
+		return Result{}, errors.New("yt-dlp found no HLS streams for " + request.String())
+	}
+	addBestAndWorst(variants)
+
+	result, err := makeResult(variants, request, nil)
+	if err != nil {
+		return Result{}, err
+	}
+	result.Headers = headers[result.URL]
+	return result, nil
+}
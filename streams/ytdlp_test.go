@@ -0,0 +1,111 @@
+package streams
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const ytDlpJSON = `{
+  "id": "somestreamer",
+  "formats": [
+    {"format_id": "audio_only", "url": "https://example.com/audio_only.m3u8", "protocol": "m3u8_native", "vcodec": "none", "tbr": 160},
+    {"format_id": "160p", "url": "https://example.com/160p.m3u8", "protocol": "m3u8_native", "vcodec": "avc1.4D401F", "width": 284, "height": 160, "fps": 30, "tbr": 230},
+    {"format_id": "720p30", "url": "https://example.com/720p30.m3u8", "protocol": "m3u8_native", "vcodec": "avc1.4D401F", "width": 1280, "height": 720, "fps": 30, "tbr": 2373},
+    {"format_id": "720p60", "url": "https://example.com/720p60.m3u8", "protocol": "m3u8_native", "vcodec": "avc1.4D401F", "width": 1280, "height": 720, "fps": 60, "tbr": 3422},
+    {"format_id": "1080p60", "url": "https://example.com/1080p60.m3u8", "protocol": "m3u8_native", "vcodec": "avc1.64002A", "width": 1920, "height": 1080, "fps": 60, "tbr": 8534,
+     "http_headers": {"User-Agent": "yt-dlp"}},
+    {"format_id": "storyboard", "url": "https://example.com/storyboard.jpg", "protocol": "mhtml", "vcodec": "none"}
+  ]
+}`
+
+func TestYtDlpResolver(t *testing.T) {
+	tests := []struct {
+		name      string
+		script    string
+		request   Request
+		wantURL   string
+		wantAgent string
+		wantErr   string
+	}{
+		{
+			name:      "best",
+			script:    `[ "$3" = "https://www.twitch.tv/somestreamer" ] || exit 3` + "\ncat <<'EOF'\n" + ytDlpJSON + "\nEOF\n",
+			request:   Request{Channel: "somestreamer", Quality: "best"},
+			wantURL:   "https://example.com/1080p60.m3u8",
+			wantAgent: "yt-dlp",
+		},
+		{
+			name:    "30fps maximum",
+			script:  "cat <<'EOF'\n" + ytDlpJSON + "\nEOF\n",
+			request: Request{Channel: "somestreamer", Quality: "720p30"},
+			wantURL: "https://example.com/720p30.m3u8",
+		},
+		{
+			name:    "audio only",
+			script:  "cat <<'EOF'\n" + ytDlpJSON + "\nEOF\n",
+			request: Request{Channel: "somestreamer", Quality: "best", Options: QualityOptions{AudioOnly: true}},
+			wantURL: "https://example.com/audio_only.m3u8",
+		},
+		{
+			name: "duplicate names keep the most bandwidth",
+			script: `echo '{"formats": [
+				{"url": "https://a.example.com/720p60.m3u8", "protocol": "m3u8", "vcodec": "avc1", "width": 1280, "height": 720, "fps": 60, "tbr": 3000},
+				{"url": "https://b.example.com/720p60.m3u8", "protocol": "m3u8_native", "vcodec": "avc1", "width": 1280, "height": 720, "fps": 60, "tbr": 3422},
+				{"url": "https://c.example.com/720p60.m3u8", "protocol": "m3u8_native", "vcodec": "avc1", "width": 1280, "height": 720, "fps": 60, "tbr": 1000}]}'` + "\n",
+			request: Request{Channel: "somestreamer", Quality: "720p60"},
+			wantURL: "https://b.example.com/720p60.m3u8",
+		},
+		{
+			name: "duplicate names with the same bandwidth keep the first",
+			script: `echo '{"formats": [
+				{"url": "https://a.example.com/720p60.m3u8", "protocol": "m3u8_native", "vcodec": "avc1", "width": 1280, "height": 720, "fps": 60, "tbr": 3422},
+				{"url": "https://b.example.com/720p60.m3u8", "protocol": "m3u8_native", "vcodec": "avc1", "width": 1280, "height": 720, "fps": 60, "tbr": 3422}]}'` + "\n",
+			request: Request{Channel: "somestreamer", Quality: "best"},
+			wantURL: "https://a.example.com/720p60.m3u8",
+		},
+		{
+			name:    "no hls formats",
+			script:  `echo '{"formats": [{"format_id": "storyboard", "url": "https://example.com/s.jpg", "protocol": "mhtml"}]}'` + "\n",
+			request: Request{Channel: "somestreamer", Quality: "best"},
+			wantErr: "yt-dlp found no HLS streams for somestreamer",
+		},
+		{
+			name:    "offline",
+			script:  "echo 'ERROR: [twitch:stream] somestreamer: The channel is not currently live' >&2\nexit 1\n",
+			request: Request{Channel: "somestreamer", Quality: "best"},
+			wantErr: "yt-dlp failed: ERROR: [twitch:stream] somestreamer: The channel is not currently live",
+		},
+		{
+			name:    "hangs",
+			script:  "exec sleep 10\n",
+			request: Request{Channel: "somestreamer", Quality: "best"},
+			wantErr: "yt-dlp did not finish within 200ms",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fakeExecutable(t, "yt-dlp", test.script)
+			resolver := NewYtDlpResolver()
+			resolver.timeout = 200 * time.Millisecond
+
+			result, err := resolver.Resolve(test.request)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.URL != test.wantURL {
+				t.Errorf("URL = %s, want %s", result.URL, test.wantURL)
+			}
+			if result.Headers["User-Agent"] != test.wantAgent {
+				t.Errorf("User-Agent = %q, want %q", result.Headers["User-Agent"], test.wantAgent)
+			}
+		})
+	}
+}